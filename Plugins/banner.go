@@ -0,0 +1,92 @@
+package Plugins
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shadow1ng/fscan/common"
+)
+
+// 未识别的服务,输出收到的前N个字节,方便人工判断协议
+func UnknownService(info *common.HostInfo) {
+	if common.BannerBytes <= 0 {
+		return
+	}
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	if common.FingerprintCached(info.Host, info.Ports) {
+		common.LogSuccess(fmt.Sprintf("[*] service %v cached fingerprint:%v", realhost, SafeAscii([]byte(strings.TrimSpace(common.Fingerprint(info.Host, info.Ports))))))
+		return
+	}
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(common.Timeout)*time.Second)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Duration(common.Timeout) * time.Second))
+	banner, _ := ReadBytes(conn)
+	name, response := ProbeService(realhost, info.Ports, banner)
+	common.SetFingerprint(info.Host, info.Ports, name+" "+string(banner))
+	if name != "" {
+		common.LogSuccess(fmt.Sprintf("[+] service %v name:%v ascii:%v", realhost, name, SafeAscii(response)))
+		return
+	}
+	if len(banner) == 0 {
+		banner = response
+	}
+	if len(banner) == 0 {
+		return
+	}
+	if len(banner) > common.BannerBytes {
+		banner = banner[:common.BannerBytes]
+	}
+	result := fmt.Sprintf("[+] unknown_service %v hex:%v ascii:%v", realhost, hex.EncodeToString(banner), SafeAscii(banner))
+	common.LogSuccess(result)
+}
+
+// 不可打印字符替换为'.'
+func SafeAscii(data []byte) string {
+	buf := make([]byte, len(data))
+	for i, b := range data {
+		if b < 0x20 || b > 0x7e {
+			buf[i] = '.'
+		} else {
+			buf[i] = b
+		}
+	}
+	return string(buf)
+}
+
+// 用 -probe-file 中的探测包识别服务: 先用已有banner匹配,
+// 没有匹配时依次发送探测包,返回识别出的名称和响应
+func ProbeService(realhost, port string, banner []byte) (string, []byte) {
+	var response []byte
+	for _, probe := range common.ProbesFor(port) {
+		if len(banner) > 0 && probe.Match != nil && probe.Match.Match(banner) {
+			return probe.Name, banner
+		}
+	}
+	for _, probe := range common.ProbesFor(port) {
+		conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(common.Timeout)*time.Second)
+		if err != nil {
+			return "", response
+		}
+		conn.SetDeadline(time.Now().Add(time.Duration(common.Timeout) * time.Second))
+		_, err = conn.Write(probe.Payload)
+		if err == nil {
+			data, _ := ReadBytes(conn)
+			if len(data) > 0 {
+				if probe.Match == nil || probe.Match.Match(data) {
+					conn.Close()
+					return probe.Name, data
+				}
+				if response == nil {
+					response = data
+				}
+			}
+		}
+		conn.Close()
+	}
+	return "", response
+}
@@ -13,11 +13,14 @@ var PluginList = map[string]interface{}{
 	"22":      SshScan,
 	"135":     Findnet,
 	"139":     NetBIOS,
+	"161":     SnmpScan,
 	"445":     SmbScan,
 	"1433":    MssqlScan,
 	"1521":    OracleScan,
 	"3306":    MysqlScan,
 	"3389":    RdpScan,
+	"5060":    SipScan,
+	"5061":    SipScan,
 	"5432":    PostgresScan,
 	"6379":    RedisScan,
 	"9000":    FcgiScan,
@@ -28,6 +31,8 @@ var PluginList = map[string]interface{}{
 	"1000003": WebTitle,
 	"1000004": SmbScan2,
 	"1000005": WmiExec,
+	"1000006": OpenProxyScan,
+	"1000007": InventoryScan,
 }
 
 func ReadBytes(conn net.Conn) (result []byte, err error) {
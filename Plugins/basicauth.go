@@ -0,0 +1,98 @@
+package Plugins
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/shadow1ng/fscan/WebScan/lib"
+	"github.com/shadow1ng/fscan/common"
+)
+
+var (
+	realmReg = regexp.MustCompile(`(?i)^\s*basic\s+realm="?([^",]*)"?`)
+	// 只试少量常见默认口令,避免触发账号锁定
+	BasicAuthCreds = []common.Cred{
+		{User: "admin", Pass: "admin"},
+		{User: "admin", Pass: "123456"},
+		{User: "admin", Pass: "password"},
+		{User: "root", Pass: "root"},
+		{User: "tomcat", Pass: "tomcat"},
+		{User: "admin", Pass: ""},
+	}
+	basicAuthDone  = make(map[string]bool)
+	basicAuthMutex sync.Mutex
+)
+
+// 记录需要basic认证的页面及realm,开启 -basic-auth 时尝试少量默认口令
+func CheckBasicAuth(url string, resp *http.Response, body []byte) {
+	if resp.StatusCode != 401 {
+		return
+	}
+	find := realmReg.FindStringSubmatch(resp.Header.Get("WWW-Authenticate"))
+	if len(find) < 2 {
+		return
+	}
+	realm := find[1]
+	key := resp.Request.URL.Host + "|" + realm
+	basicAuthMutex.Lock()
+	if basicAuthDone[key] {
+		basicAuthMutex.Unlock()
+		return
+	}
+	basicAuthDone[key] = true
+	basicAuthMutex.Unlock()
+	common.LogSuccess(fmt.Sprintf("[*] BasicAuth %v realm:%q", url, realm))
+	if !common.BasicAuth || common.IsBrute {
+		return
+	}
+	creds := BasicAuthCreds
+	host, port := resp.Request.URL.Hostname(), resp.Request.URL.Port()
+	if port == "" {
+		port = "80"
+		if resp.Request.URL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	common.SetFingerprint(host, port, realm+" "+resp.Header.Get("Server")+" "+gettitle(body))
+	// realm 往往只是设备型号,再结合该端口已有的指纹(banner等)和401页面内容匹配产品(海康、大华、TP-Link等)
+	var products []string
+	for _, product := range common.MatchProduct(common.Fingerprint(host, port) + " " + string(body)) {
+		creds = append(append([]common.Cred{}, product.Creds...), creds...)
+		products = append(products, product.Product)
+	}
+	for _, cred := range creds {
+		common.BruteLimiter.Wait()
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return
+		}
+		req.Header.Set("User-agent", common.UserAgent)
+		req.Header.Set("Connection", "close")
+		req.SetBasicAuth(cred.User, cred.Pass)
+		authResp, err := lib.ClientNoRedirect.Do(req)
+		if err != nil {
+			common.LogError(fmt.Sprintf("[-] basicauth %v %v %v %v", url, cred.User, cred.Pass, err))
+			return
+		}
+		authResp.Body.Close()
+		switch {
+		case authResp.StatusCode == 403 || authResp.StatusCode == 429:
+			// 可能已触发锁定或限速,停止尝试
+			common.LogError(fmt.Sprintf("[-] basicauth %v stopped, got %v", url, authResp.StatusCode))
+			return
+		case authResp.StatusCode < 200 || authResp.StatusCode > 299:
+			// 401 以及 5xx、跳转等都不能说明口令正确,只有2xx算成功
+			common.LogError(fmt.Sprintf("[-] basicauth %v %v %v %v", url, cred.User, cred.Pass, authResp.StatusCode))
+		default:
+			result := fmt.Sprintf("[+] BasicAuth %v realm:%q %v:%v code:%v", url, realm, cred.User, cred.Pass, authResp.StatusCode)
+			if len(products) > 0 {
+				result += fmt.Sprintf(" product:%v", strings.Join(products, ","))
+			}
+			common.LogSuccess(result + " [high]")
+			return
+		}
+	}
+}
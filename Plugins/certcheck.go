@@ -0,0 +1,82 @@
+package Plugins
+
+import (
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shadow1ng/fscan/common"
+)
+
+type CertInfo struct {
+	Target    string    `json:"target"`
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	Status    string    `json:"status"`
+}
+
+var (
+	CertList  = make(map[string]CertInfo)
+	certMutex sync.Mutex
+)
+
+// 检查证书有效期,已过期/未生效为medium,即将过期为low
+func CheckCert(target string, cert *x509.Certificate) {
+	certMutex.Lock()
+	if _, ok := CertList[target]; ok {
+		certMutex.Unlock()
+		return
+	}
+	now := time.Now()
+	info := CertInfo{
+		Target:    target,
+		Subject:   cert.Subject.CommonName,
+		Issuer:    cert.Issuer.CommonName,
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		Status:    "ok",
+	}
+	days := int(cert.NotAfter.Sub(now).Hours() / 24)
+	var result string
+	switch {
+	case now.After(cert.NotAfter):
+		info.Status = "expired"
+		result = fmt.Sprintf("[+] CertExpired %v CN:%v expired:%v days:%d [medium]", target, info.Subject, cert.NotAfter.Format("2006-01-02"), days)
+	case now.Before(cert.NotBefore):
+		info.Status = "notyetvalid"
+		result = fmt.Sprintf("[+] CertNotYetValid %v CN:%v notbefore:%v expire:%v days:%d [medium]", target, info.Subject, cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"), days)
+	case common.CertExpiryWarnTime > 0 && cert.NotAfter.Sub(now) < common.CertExpiryWarnTime:
+		info.Status = "expiring"
+		result = fmt.Sprintf("[+] CertExpiring %v CN:%v expire:%v days:%d [low]", target, info.Subject, cert.NotAfter.Format("2006-01-02"), days)
+	}
+	CertList[target] = info
+	certMutex.Unlock()
+	if result != "" {
+		common.LogSuccess(result)
+	}
+}
+
+// 扫描结束时输出证书清单,方便统一续期
+func CertInventory() {
+	certMutex.Lock()
+	defer certMutex.Unlock()
+	if len(CertList) == 0 {
+		return
+	}
+	var targets []string
+	for target := range CertList {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	common.LogSuccess(fmt.Sprintf("[*] CertInventory %d certs", len(targets)))
+	for _, target := range targets {
+		info := CertList[target]
+		days := int(time.Until(info.NotAfter).Hours() / 24)
+		result := fmt.Sprintf("   [->] %-30v CN:%v issuer:%v expire:%v days:%d status:%v", target, info.Subject, info.Issuer, info.NotAfter.Format("2006-01-02"), days, info.Status)
+		common.LogSuccess(result)
+	}
+}
@@ -0,0 +1,53 @@
+package Plugins
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/shadow1ng/fscan/common"
+)
+
+var (
+	// apache/nginx/lighttpd: Index of /, iis: [To Parent Directory], python: Directory listing for
+	listingTitleReg = regexp.MustCompile(`(?i)<title>\s*(Index of /|Directory listing for /|[^<]* - /</title>)`)
+	listingBodyReg  = regexp.MustCompile(`(?i)(<h1>\s*Index of /|\[To Parent Directory\]|<h1>\s*Directory listing for /|Parent Directory</a>)`)
+	listingHrefReg  = regexp.MustCompile(`(?i)<a href="([^"?#]+)"`)
+	sensitiveReg    = regexp.MustCompile(`(?i)(\.(bak|old|backup|sql|zip|rar|7z|tar|gz|tgz|swp|env|pem|key|conf|config|ini|log|mdb|db)$|^\.git/?$|^\.svn/?$|backup|config|passw|id_rsa|web\.xml)`)
+)
+
+// 识别自动生成的目录列表,要求标题和正文特征同时命中,避免把普通带链接的页面误报
+func CheckDirListing(url string, body []byte) {
+	if !listingTitleReg.Match(body) || !listingBodyReg.Match(body) {
+		return
+	}
+	var entries, sensitive []string
+	for _, find := range listingHrefReg.FindAllSubmatch(body, -1) {
+		name := string(find[1])
+		if name == "/" || name == "../" || strings.HasPrefix(name, "http") {
+			continue
+		}
+		if strings.HasPrefix(name, "/") {
+			name = name[strings.LastIndex(strings.TrimSuffix(name, "/"), "/")+1:]
+		}
+		if name == "" || strings.EqualFold(name, "Parent Directory") {
+			continue
+		}
+		entries = append(entries, name)
+		if sensitiveReg.MatchString(name) {
+			sensitive = append(sensitive, name)
+		}
+	}
+	if len(entries) > 10 {
+		entries = entries[:10]
+	}
+	level := "medium"
+	if len(sensitive) > 0 {
+		level = "high"
+	}
+	result := fmt.Sprintf("[+] DirListing %v entries:%v", url, entries)
+	if len(sensitive) > 0 {
+		result += fmt.Sprintf(" sensitive:%v", sensitive)
+	}
+	common.LogSuccess(result + " [" + level + "]")
+}
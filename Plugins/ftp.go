@@ -13,6 +13,16 @@ func FtpScan(info *common.HostInfo) (tmperr error) {
 		return
 	}
 	starttime := time.Now().Unix()
+	if common.TryHostCreds(info.Host, "ftp", func(user, pass string) (bool, error) {
+		return FtpConn(info, user, pass)
+	}) {
+		return
+	}
+	if common.TryProductCreds(info.Host, info.Ports, "ftp", func(user, pass string) (bool, error) {
+		return FtpConn(info, user, pass)
+	}) {
+		return
+	}
 	flag, err := FtpConn(info, "anonymous", "")
 	if flag && err == nil {
 		return err
@@ -28,6 +38,10 @@ func FtpScan(info *common.HostInfo) (tmperr error) {
 	for _, user := range common.Userdict["ftp"] {
 		for _, pass := range common.Passwords {
 			pass = strings.Replace(pass, "{user}", user, -1)
+			if info.Context().Err() != nil {
+				return tmperr
+			}
+			common.BruteLimiter.Wait()
 			flag, err := FtpConn(info, user, pass)
 			if flag && err == nil {
 				return err
@@ -72,6 +86,7 @@ func FtpConn(info *common.HostInfo, user string, pass string) (flag bool, err er
 					}
 				}
 			}
+			common.RecordCred("ftp", Host, Password)
 			common.LogSuccess(result)
 		}
 	}
@@ -0,0 +1,112 @@
+package Plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/shadow1ng/fscan/WebScan/lib"
+	"github.com/shadow1ng/fscan/common"
+)
+
+// YARN ResourceManager 8088/8090, NodeManager 8042, NameNode 9870/50070, JobHistory 19888
+var hadoopPorts = []string{"8088", "8090", "8042", "9870", "50070", "19888"}
+
+// 识别未授权的Hadoop/YARN/HDFS web界面,只检测不提交任务
+func HadoopScan(info *common.HostInfo) {
+	u, err := url.Parse(info.Url)
+	if err != nil {
+		return
+	}
+	base := u.Scheme + "://" + u.Host
+	var cluster struct {
+		ClusterInfo struct {
+			State                  string `json:"state"`
+			HadoopVersion          string `json:"hadoopVersion"`
+			ResourceManagerVersion string `json:"resourceManagerVersion"`
+		} `json:"clusterInfo"`
+	}
+	if hadoopGet(base+"/ws/v1/cluster/info", &cluster) && cluster.ClusterInfo.HadoopVersion != "" {
+		// new-application 只申请一个应用id,不会真正提交运行任务
+		if id := yarnNewApplication(base); id != "" {
+			common.LogSuccess(fmt.Sprintf("[+] YARN-RM %v version:%v state:%v unauth job submission allowed (app id %v) [critical]", base, cluster.ClusterInfo.HadoopVersion, cluster.ClusterInfo.State, id))
+		} else {
+			common.LogSuccess(fmt.Sprintf("[+] YARN-RM %v version:%v state:%v unauth web ui [high]", base, cluster.ClusterInfo.HadoopVersion, cluster.ClusterInfo.State))
+		}
+		return
+	}
+	var node struct {
+		NodeInfo struct {
+			NodeHostName       string `json:"nodeHostName"`
+			NodeManagerVersion string `json:"nodeManagerVersion"`
+		} `json:"nodeInfo"`
+	}
+	if hadoopGet(base+"/ws/v1/node/info", &node) && node.NodeInfo.NodeManagerVersion != "" {
+		common.LogSuccess(fmt.Sprintf("[+] YARN-NM %v version:%v host:%v unauth web ui [medium]", base, node.NodeInfo.NodeManagerVersion, node.NodeInfo.NodeHostName))
+		return
+	}
+	var jmx struct {
+		Beans []struct {
+			SoftwareVersion string `json:"SoftwareVersion"`
+			ClusterId       string `json:"ClusterId"`
+		} `json:"beans"`
+	}
+	if hadoopGet(base+"/jmx?qry=Hadoop:service=NameNode,name=NameNodeInfo", &jmx) && len(jmx.Beans) > 0 {
+		common.LogSuccess(fmt.Sprintf("[+] HDFS-NameNode %v version:%v cluster:%v unauth web ui [high]", base, jmx.Beans[0].SoftwareVersion, jmx.Beans[0].ClusterId))
+		return
+	}
+	var history struct {
+		HistoryInfo struct {
+			HadoopVersion string `json:"hadoopVersion"`
+		} `json:"historyInfo"`
+	}
+	if hadoopGet(base+"/ws/v1/history/info", &history) && history.HistoryInfo.HadoopVersion != "" {
+		common.LogSuccess(fmt.Sprintf("[+] MR-JobHistory %v version:%v unauth web ui [medium]", base, history.HistoryInfo.HadoopVersion))
+	}
+}
+
+func hadoopGet(target string, v interface{}) bool {
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("User-agent", common.UserAgent)
+	req.Header.Set("Accept", "application/json")
+	resp, err := lib.Client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return false
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(body, v) == nil
+}
+
+func yarnNewApplication(base string) string {
+	req, err := http.NewRequest("POST", base+"/ws/v1/cluster/apps/new-application", nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("User-agent", common.UserAgent)
+	req.Header.Set("Accept", "application/json")
+	resp, err := lib.Client.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	var app struct {
+		ApplicationId string `json:"application-id"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode != 200 || json.Unmarshal(body, &app) != nil {
+		return ""
+	}
+	return app.ApplicationId
+}
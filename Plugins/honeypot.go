@@ -0,0 +1,145 @@
+package Plugins
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shadow1ng/fscan/common"
+)
+
+// 同一/24内绝大多数主机开放完全相同的端口且banner一致,多半是防火墙/蜜罐在替整段应答
+const (
+	rangeHoneypotMinHosts = 16
+	rangeHoneypotRatio    = 0.9
+)
+
+// 端口扫描后按/24分析,返回去掉可疑网段(开启 -drop-range-honeypot 时)后的存活端口
+func RangeHoneypot(hosts []string, alivePorts []string) []string {
+	scanned := make(map[string]int)
+	for _, host := range hosts {
+		if index := strings.LastIndex(host, "."); index != -1 && !strings.Contains(host, ":") {
+			scanned[host[:index]]++
+		}
+	}
+	ports := make(map[string][]string)
+	for _, address := range alivePorts {
+		index := strings.LastIndex(address, ":")
+		ports[address[:index]] = append(ports[address[:index]], address[index+1:])
+	}
+	// subnet -> 端口组合 -> 主机
+	signatures := make(map[string]map[string][]string)
+	for host, list := range ports {
+		index := strings.LastIndex(host, ".")
+		if index == -1 || strings.Contains(host, ":") {
+			continue
+		}
+		sort.Strings(list)
+		subnet := host[:index]
+		if signatures[subnet] == nil {
+			signatures[subnet] = make(map[string][]string)
+		}
+		signature := strings.Join(list, ",")
+		signatures[subnet][signature] = append(signatures[subnet][signature], host)
+	}
+	suspects := make(map[string]bool)
+	for subnet, groups := range signatures {
+		for signature, members := range groups {
+			if len(members) < rangeHoneypotMinHosts || float64(len(members)) < float64(scanned[subnet])*rangeHoneypotRatio {
+				continue
+			}
+			port := strings.Split(signature, ",")[0]
+			if !sameBanner(members, port) {
+				continue
+			}
+			suspects[subnet] = true
+			result := fmt.Sprintf("[+] RangeHoneypot %s.0/24 %d/%d hosts open the same ports [%s] with identical banner, likely a firewall/honeypot answering for the whole range, please verify [medium]", subnet, len(members), scanned[subnet], signature)
+			if common.DropRangeHoneypot {
+				result += " (suppressed)"
+			}
+			common.LogSuccess(result)
+		}
+	}
+	if !common.DropRangeHoneypot || len(suspects) == 0 {
+		return alivePorts
+	}
+	var newDatas []string
+	for _, address := range alivePorts {
+		host := address[:strings.LastIndex(address, ":")]
+		if index := strings.LastIndex(host, "."); index != -1 && suspects[host[:index]] {
+			continue
+		}
+		newDatas = append(newDatas, address)
+	}
+	return newDatas
+}
+
+// 抽样3台主机读取banner,都一致(包括都不发banner)则认为一致
+func sameBanner(hosts []string, port string) bool {
+	var first []byte
+	for i, host := range hosts {
+		if i >= 3 {
+			break
+		}
+		var banner []byte
+		conn, err := common.WrapperTcpWithTimeout("tcp", host+":"+port, time.Duration(common.Timeout)*time.Second)
+		if err != nil {
+			return false
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Duration(common.Timeout) * time.Second))
+		banner, _ = ReadBytes(conn)
+		conn.Close()
+		if i == 0 {
+			first = banner
+		} else if !bytes.Equal(first, banner) {
+			return false
+		}
+	}
+	return true
+}
+
+// 单台主机开放一长串连续端口(如1-1000全开)是典型的蜜罐特征,只标记不删除结果
+func SequentialHoneypot(alivePorts []string) {
+	if common.HoneypotRun <= 0 {
+		return
+	}
+	ports := make(map[string][]int)
+	var hosts []string
+	for _, address := range alivePorts {
+		index := strings.LastIndex(address, ":")
+		port, err := strconv.Atoi(address[index+1:])
+		if err != nil {
+			continue
+		}
+		host := address[:index]
+		if _, ok := ports[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		ports[host] = append(ports[host], port)
+	}
+	for _, host := range hosts {
+		list := ports[host]
+		if len(list) < common.HoneypotRun {
+			continue
+		}
+		sort.Ints(list)
+		bestStart, bestLen := 0, 0
+		for i := 0; i < len(list); {
+			j := i + 1
+			for j < len(list) && list[j] == list[j-1]+1 {
+				j++
+			}
+			if j-i > bestLen {
+				bestStart, bestLen = i, j-i
+			}
+			i = j
+		}
+		if bestLen >= common.HoneypotRun {
+			result := fmt.Sprintf("[*] SeqHoneypot %s open ports %d-%d contiguous (%d ports, %d open in total), likely a honeypot/decoy [info]", host, list[bestStart], list[bestStart+bestLen-1], bestLen, len(list))
+			common.LogSuccess(result)
+		}
+	}
+}
@@ -20,6 +20,8 @@ var (
 )
 
 func CheckLive(hostslist []string, Ping bool) []string {
+	// 分批扫描时每批单独统计,只返回本批的存活主机
+	AliveHosts, ExistHosts = nil, make(map[string]struct{})
 	chanHosts := make(chan string, len(hostslist))
 	go func() {
 		for ip := range chanHosts {
@@ -0,0 +1,181 @@
+package Plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shadow1ng/fscan/common"
+)
+
+// 资产清单,每台主机一条记录,只做端口和指纹识别,不爆破不打poc
+type Asset struct {
+	Host     string            `json:"host"`
+	Hostname string            `json:"hostname,omitempty"`
+	OS       string            `json:"os,omitempty"`
+	Ports    []int             `json:"ports"`
+	Services map[string]string `json:"services,omitempty"`
+	Certs    []CertInfo        `json:"certs,omitempty"`
+}
+
+var (
+	Assets     = make(map[string]*Asset)
+	assetMutex sync.Mutex
+	serverReg  = regexp.MustCompile(`Server:\[([^\]]*)\]`)
+	osKeywords = []string{"Windows", "Ubuntu", "Debian", "CentOS", "Red Hat", "FreeBSD", "Raspbian", "Linux"}
+)
+
+func getAsset(host string) *Asset {
+	asset, ok := Assets[host]
+	if !ok {
+		asset = &Asset{Host: host, Services: make(map[string]string)}
+		Assets[host] = asset
+	}
+	return asset
+}
+
+func AssetPort(host string, port int) {
+	assetMutex.Lock()
+	asset := getAsset(host)
+	asset.Ports = append(asset.Ports, port)
+	assetMutex.Unlock()
+}
+
+func AssetService(host, port, service string) {
+	assetMutex.Lock()
+	asset := getAsset(host)
+	asset.Services[port] = service
+	if asset.OS == "" {
+		for _, keyword := range osKeywords {
+			if strings.Contains(strings.ToLower(service), strings.ToLower(keyword)) {
+				asset.OS = keyword
+				break
+			}
+		}
+		if strings.Contains(service, "Microsoft") {
+			asset.OS = "Windows"
+		}
+	}
+	assetMutex.Unlock()
+}
+
+func InventoryScan(info *common.HostInfo) error {
+	switch info.Ports {
+	case "139", "445":
+		netbios, _ := NetBIOS1(info)
+		assetMutex.Lock()
+		asset := getAsset(info.Host)
+		if netbios.OsVersion != "" {
+			asset.OS = netbios.OsVersion
+		}
+		if name := netbios.String(); name != "" {
+			asset.Hostname = name
+		}
+		assetMutex.Unlock()
+		AssetService(info.Host, info.Ports, "smb")
+		return nil
+	}
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(common.Timeout)*time.Second)
+	if err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Duration(common.Timeout) * time.Second))
+	banner, _ := ReadBytes(conn)
+	conn.Close()
+	if service := bannerService(realhost, banner); service != "" {
+		AssetService(info.Host, info.Ports, service)
+		return nil
+	}
+	if name, _ := ProbeService(realhost, info.Ports, banner); name != "" {
+		AssetService(info.Host, info.Ports, name)
+		return nil
+	}
+	// 不主动发banner的按web处理
+	err, CheckData := GOWebTitle(info)
+	if err != nil && len(CheckData) == 0 {
+		if len(banner) > 0 {
+			AssetService(info.Host, info.Ports, "unknown "+SafeAscii(banner))
+		}
+		return err
+	}
+	service := "http"
+	if strings.HasPrefix(info.Url, "https") {
+		service = "https"
+	}
+	for _, data := range CheckData {
+		if find := serverReg.FindStringSubmatch(data.Headers); len(find) > 1 {
+			service += " " + find[1]
+			break
+		}
+	}
+	AssetService(info.Host, info.Ports, service)
+	return nil
+}
+
+func bannerService(realhost string, banner []byte) string {
+	if len(banner) == 0 {
+		return ""
+	}
+	text := strings.TrimSpace(strings.SplitN(string(banner), "\n", 2)[0])
+	switch {
+	case strings.HasPrefix(text, "SSH-"):
+		return "ssh " + text
+	case len(banner) > 4 && banner[4] == 10:
+		if version, plugin, err := MysqlHandshake(realhost); err == nil {
+			return fmt.Sprintf("mysql %s (%s)", version, plugin)
+		}
+	case strings.HasPrefix(text, "220") && strings.Contains(strings.ToLower(text), "ftp"):
+		return "ftp " + text
+	case strings.HasPrefix(text, "220"):
+		return "smtp " + text
+	case strings.HasPrefix(text, "+OK"):
+		return "pop3 " + text
+	case strings.HasPrefix(text, "* OK"):
+		return "imap " + text
+	}
+	return ""
+}
+
+func SaveInventory(filename string) {
+	assetMutex.Lock()
+	defer assetMutex.Unlock()
+	certMutex.Lock()
+	for _, cert := range CertList {
+		host := strings.TrimPrefix(cert.Target, "https://")
+		if index := strings.LastIndex(host, ":"); index != -1 {
+			host = host[:index]
+		}
+		if asset, ok := Assets[host]; ok {
+			asset.Certs = append(asset.Certs, cert)
+		}
+	}
+	certMutex.Unlock()
+	var hosts []string
+	for host := range Assets {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	var list []*Asset
+	for _, host := range hosts {
+		asset := Assets[host]
+		sort.Ints(asset.Ports)
+		list = append(list, asset)
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		fmt.Println("[-] inventory marshal error:", err)
+		return
+	}
+	if err = os.WriteFile(filename, data, 0666); err != nil {
+		fmt.Printf("[-] Write %s error, %v\n", filename, err)
+		return
+	}
+	fmt.Println("[*] inventory saved to " + filename + ", hosts: " + strconv.Itoa(len(list)))
+}
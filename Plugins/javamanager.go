@@ -0,0 +1,134 @@
+package Plugins
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/shadow1ng/fscan/WebScan"
+	"github.com/shadow1ng/fscan/WebScan/lib"
+	"github.com/shadow1ng/fscan/common"
+)
+
+type javaConsole struct {
+	Name   string
+	Path   string
+	Marker string // 登录成功后页面中的特征
+}
+
+var (
+	javaServerReg  = regexp.MustCompile(`(?i)tomcat|jboss|wildfly|coyote|undertow|jsessionid`)
+	javaVersionReg = regexp.MustCompile(`(?i)(Apache Tomcat|JBoss[A-Za-z-]*|WildFly)[/ ]?(\d+(?:\.[\w-]+)*)`)
+	javaConsoles   = []javaConsole{
+		{"tomcat-manager", "/manager/html", "Tomcat Web Application Manager"},
+		{"tomcat-host-manager", "/host-manager/html", "Tomcat Virtual Host Manager"},
+		{"jboss-jmx-console", "/jmx-console/", "JMX Agent View"},
+		{"jboss-web-console", "/web-console/", "Web Console"},
+	}
+	// 管理后台的常见默认口令,只用于确认能否登录,不会部署war
+	JavaManagerCreds = []common.Cred{
+		{User: "tomcat", Pass: "tomcat"},
+		{User: "admin", Pass: "admin"},
+		{User: "tomcat", Pass: "s3cret"},
+		{User: "admin", Pass: ""},
+		{User: "both", Pass: "tomcat"},
+		{User: "role1", Pass: "role1"},
+		{User: "manager", Pass: "manager"},
+		{User: "admin", Pass: "tomcat"},
+	}
+)
+
+func isJavaServer(info *common.HostInfo, CheckData []WebScan.CheckDatas) bool {
+	if javaServerReg.MatchString(strings.Join(info.Infostr, " ")) {
+		return true
+	}
+	for _, data := range CheckData {
+		if javaServerReg.MatchString(data.Headers) || javaServerReg.Match(data.Body) {
+			return true
+		}
+	}
+	return false
+}
+
+// 探测Tomcat/JBoss管理后台,未授权或默认口令可登录即可部署war,记为critical;只做检测不部署
+func JavaManagerScan(info *common.HostInfo) {
+	u, err := url.Parse(info.Url)
+	if err != nil {
+		return
+	}
+	base := u.Scheme + "://" + u.Host
+	// 不存在页面的404会带出tomcat版本
+	_, header, body := managerGet(base+"/fscan-not-found-"+common.RunID, nil)
+	version := javaVersion(header, body)
+	for _, console := range javaConsoles {
+		target := base + console.Path
+		code, header, body := managerGet(target, nil)
+		if v := javaVersion(header, body); v != "" && version == "" {
+			version = v
+		}
+		switch {
+		case code == 200 && strings.Contains(string(body), console.Marker):
+			common.LogSuccess(fmt.Sprintf("[+] JavaManager %v %v version:%v no auth, war deploy possible [critical]", target, console.Name, version))
+		case code == 401:
+			common.LogSuccess(fmt.Sprintf("[*] JavaManager %v %v version:%v login required", target, console.Name, version))
+			if !common.IsBrute {
+				javaManagerBrute(u.Hostname(), target, console, version)
+			}
+		case code == 403:
+			common.LogSuccess(fmt.Sprintf("[*] JavaManager %v %v version:%v forbidden (access restricted)", target, console.Name, version))
+		}
+	}
+}
+
+func javaManagerBrute(host, target string, console javaConsole, version string) {
+	for _, cred := range JavaManagerCreds {
+		common.BruteLimiter.Wait()
+		code, _, body := managerGet(target, &cred)
+		switch {
+		case code == 200 && strings.Contains(string(body), console.Marker):
+			common.RecordCred("javamanager", host, cred.Pass)
+			common.LogSuccess(fmt.Sprintf("[+] JavaManager %v %v version:%v %v %v, war deploy possible [critical]", target, console.Name, version, cred.User, cred.Pass))
+			return
+		case code == 403 && console.Name == "tomcat-manager":
+			// 口令正确但没有manager-gui角色时返回403
+			common.LogSuccess(fmt.Sprintf("[+] JavaManager %v %v version:%v %v %v valid, no manager-gui role [medium]", target, console.Name, version, cred.User, cred.Pass))
+			return
+		case code == 0 || code == 429:
+			common.LogError(fmt.Sprintf("[-] javamanager %v stopped, got %v", target, code))
+			return
+		default:
+			common.LogError(fmt.Sprintf("[-] javamanager %v %v %v %v", target, cred.User, cred.Pass, code))
+		}
+	}
+}
+
+func managerGet(target string, cred *common.Cred) (int, string, []byte) {
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return 0, "", nil
+	}
+	req.Header.Set("User-agent", common.UserAgent)
+	if cred != nil {
+		req.SetBasicAuth(cred.User, cred.Pass)
+	}
+	resp, err := lib.ClientNoRedirect.Do(req)
+	if err != nil {
+		return 0, "", nil
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	return resp.StatusCode, fmt.Sprintf("%s", resp.Header), body
+}
+
+func javaVersion(header string, body []byte) string {
+	if find := javaVersionReg.FindStringSubmatch(header); len(find) > 2 {
+		return find[1] + "/" + find[2]
+	}
+	if find := javaVersionReg.FindSubmatch(body); len(find) > 2 {
+		return string(find[1]) + "/" + string(find[2])
+	}
+	return ""
+}
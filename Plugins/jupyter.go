@@ -0,0 +1,71 @@
+package Plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/shadow1ng/fscan/WebScan"
+	"github.com/shadow1ng/fscan/WebScan/lib"
+	"github.com/shadow1ng/fscan/common"
+)
+
+var jupyterPorts = []string{"8888", "8889", "8890"}
+
+func isJupyter(info *common.HostInfo, CheckData []WebScan.CheckDatas) bool {
+	if IsContain(jupyterPorts, info.Ports) {
+		return true
+	}
+	for _, data := range CheckData {
+		if strings.Contains(strings.ToLower(string(data.Body)), "jupyter") {
+			return true
+		}
+	}
+	return false
+}
+
+// 识别Jupyter Notebook/JupyterLab,无token可直接访问 /api/contents 等同于远程代码执行;
+// 需要token时只记录,默认不爆破token
+func JupyterScan(info *common.HostInfo) {
+	u, err := url.Parse(info.Url)
+	if err != nil {
+		return
+	}
+	base := u.Scheme + "://" + u.Host
+	code, body := jupyterGet(base + "/api")
+	var api struct {
+		Version string `json:"version"`
+	}
+	if code != 200 || json.Unmarshal(body, &api) != nil || api.Version == "" {
+		return
+	}
+	product := "Jupyter"
+	if code, _ := jupyterGet(base + "/lab"); code == 200 {
+		product = "JupyterLab"
+	}
+	code, _ = jupyterGet(base + "/api/contents")
+	switch code {
+	case 200:
+		common.LogSuccess(fmt.Sprintf("[+] %v %v version:%v no token required, unauth code execution [critical]", product, base, api.Version))
+	case 401, 403:
+		common.LogSuccess(fmt.Sprintf("[*] %v %v version:%v token required", product, base, api.Version))
+	}
+}
+
+func jupyterGet(target string) (int, []byte) {
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return 0, nil
+	}
+	req.Header.Set("User-agent", common.UserAgent)
+	resp, err := lib.ClientNoRedirect.Do(req)
+	if err != nil {
+		return 0, nil
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	return resp.StatusCode, body
+}
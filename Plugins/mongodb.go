@@ -1,22 +1,264 @@
 package Plugins
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/shadow1ng/fscan/common"
+	"golang.org/x/crypto/pbkdf2"
+	"io"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 )
 
-func MongodbScan(info *common.HostInfo) error {
+func MongodbScan(info *common.HostInfo) (tmperr error) {
 	if common.IsBrute {
 		return nil
 	}
-	_, err := MongodbUnauth(info)
+	flag, err := MongodbUnauth(info)
 	if err != nil {
 		errlog := fmt.Sprintf("[-] Mongodb %v:%v %v", info.Host, info.Ports, err)
 		common.LogError(errlog)
 	}
-	return err
+	if flag || err != nil {
+		return err
+	}
+	starttime := time.Now().Unix()
+	try := func(user, pass string) (bool, error) {
+		return MongodbConn(info, user, pass)
+	}
+	if common.TryHostCreds(info.Host, "mongodb", try) || common.TryProductCreds(info.Host, info.Ports, "mongodb", try) {
+		return
+	}
+	for _, user := range common.Userdict["mongodb"] {
+		for _, pass := range common.Passwords {
+			pass = strings.Replace(pass, "{user}", user, -1)
+			if info.Context().Err() != nil {
+				return tmperr
+			}
+			common.BruteLimiter.Wait()
+			flag, err := MongodbConn(info, user, pass)
+			if flag == true && err == nil {
+				return err
+			} else {
+				errlog := fmt.Sprintf("[-] mongodb %v:%v %v %v %v", info.Host, info.Ports, user, pass, err)
+				common.LogError(errlog)
+				tmperr = err
+				if common.CheckErrs(err) {
+					return err
+				}
+				if time.Now().Unix()-starttime > (int64(len(common.Userdict["mongodb"])*len(common.Passwords)) * common.Timeout) {
+					return err
+				}
+			}
+		}
+	}
+	return tmperr
+}
+
+// SCRAM-SHA-1 认证(admin库),MongoDB 3.0 起的默认机制,4.0 之后的 SCRAM-SHA-256 用户通常也保留 SHA-1 凭据
+func MongodbConn(info *common.HostInfo, user string, pass string) (flag bool, err error) {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(common.Timeout)*time.Second)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(time.Duration(common.Timeout) * time.Second)); err != nil {
+		return false, err
+	}
+	nonce := make([]byte, 24)
+	if _, err = rand.Read(nonce); err != nil {
+		return false, err
+	}
+	clientNonce := base64.StdEncoding.EncodeToString(nonce)
+	firstBare := "n=" + strings.NewReplacer("=", "=3D", ",", "=2C").Replace(user) + ",r=" + clientNonce
+	reply, err := mongoCommand(conn, bsonDoc(
+		bsonInt32("saslStart", 1),
+		bsonString("mechanism", "SCRAM-SHA-1"),
+		bsonBinary("payload", []byte("n,,"+firstBare)),
+		bsonInt32("autoAuthorize", 1),
+		bsonString("$db", "admin"),
+	))
+	if err != nil {
+		return false, err
+	}
+	serverFirst := string(reply["payload"])
+	fields := make(map[string]string)
+	for _, field := range strings.Split(serverFirst, ",") {
+		if len(field) > 2 && field[1] == '=' {
+			fields[field[:1]] = field[2:]
+		}
+	}
+	salt, err := base64.StdEncoding.DecodeString(fields["s"])
+	iter, _ := strconv.Atoi(fields["i"])
+	if err != nil || iter <= 0 || !strings.HasPrefix(fields["r"], clientNonce) {
+		return false, errors.New("invalid scram server-first-message")
+	}
+	sum := md5.Sum([]byte(user + ":mongo:" + pass))
+	salted := pbkdf2.Key([]byte(hex.EncodeToString(sum[:])), salt, iter, sha1.Size, sha1.New)
+	finalBare := "c=biws,r=" + fields["r"]
+	clientKey := scramHmac(salted, "Client Key")
+	storedKey := sha1.Sum(clientKey)
+	signature := scramHmac(storedKey[:], firstBare+","+serverFirst+","+finalBare)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ signature[i]
+	}
+	_, err = mongoCommand(conn, bsonDoc(
+		bsonInt32("saslContinue", 1),
+		bsonRaw(0x10, "conversationId", reply["conversationId"]),
+		bsonBinary("payload", []byte(finalBare+",p="+base64.StdEncoding.EncodeToString(proof))),
+		bsonString("$db", "admin"),
+	))
+	if err != nil {
+		return false, err
+	}
+	result := fmt.Sprintf("[+] Mongodb %v:%v:%v %v", info.Host, info.Ports, user, pass)
+	common.RecordCred("mongodb", info.Host, pass)
+	common.LogSuccess(result)
+	return true, nil
+}
+
+func scramHmac(key []byte, data string) []byte {
+	mac := hmac.New(sha1.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// 发送 OP_MSG 命令,返回应答文档中的顶层字段;ok 不为1时返回 errmsg
+func mongoCommand(conn io.ReadWriter, doc []byte) (map[string][]byte, error) {
+	msg := make([]byte, 21, 21+len(doc))
+	binary.LittleEndian.PutUint32(msg[0:], uint32(21+len(doc)))
+	binary.LittleEndian.PutUint32(msg[4:], 1)     // requestID
+	binary.LittleEndian.PutUint32(msg[12:], 2013) // opCode OP_MSG
+	msg = append(msg, doc...)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	length := binary.LittleEndian.Uint32(header)
+	if length < 21 || length > 16<<20 || binary.LittleEndian.Uint32(header[12:]) != 2013 {
+		return nil, errors.New("invalid mongodb reply")
+	}
+	body := make([]byte, length-16)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return nil, err
+	}
+	// flagBits(4) + section kind 0(1)
+	reply, err := bsonFields(body[5:])
+	if err != nil {
+		return nil, err
+	}
+	ok := reply["ok"]
+	if len(ok) == 8 && math.Float64frombits(binary.LittleEndian.Uint64(ok)) == 1 || len(ok) == 4 && binary.LittleEndian.Uint32(ok) == 1 {
+		return reply, nil
+	}
+	if msg := reply["errmsg"]; len(msg) > 0 {
+		return nil, errors.New(string(msg))
+	}
+	return nil, errors.New("mongodb command failed")
+}
+
+// 只实现认证用到的几种bson类型
+func bsonDoc(elems ...[]byte) []byte {
+	doc := make([]byte, 4)
+	for _, elem := range elems {
+		doc = append(doc, elem...)
+	}
+	doc = append(doc, 0)
+	binary.LittleEndian.PutUint32(doc, uint32(len(doc)))
+	return doc
+}
+
+func bsonRaw(kind byte, name string, value []byte) []byte {
+	elem := append([]byte{kind}, name...)
+	return append(append(elem, 0), value...)
+}
+
+func bsonInt32(name string, value int32) []byte {
+	return bsonRaw(0x10, name, binary.LittleEndian.AppendUint32(nil, uint32(value)))
+}
+
+func bsonString(name, value string) []byte {
+	raw := binary.LittleEndian.AppendUint32(nil, uint32(len(value)+1))
+	return bsonRaw(0x02, name, append(append(raw, value...), 0))
+}
+
+func bsonBinary(name string, value []byte) []byte {
+	raw := binary.LittleEndian.AppendUint32(nil, uint32(len(value)))
+	return bsonRaw(0x05, name, append(append(raw, 0), value...))
+}
+
+// 解析文档的顶层字段,字符串和二进制返回内容本身,其余类型返回原始字节
+func bsonFields(doc []byte) (map[string][]byte, error) {
+	invalid := errors.New("invalid bson")
+	if len(doc) < 5 {
+		return nil, invalid
+	}
+	end := int(binary.LittleEndian.Uint32(doc))
+	if end > len(doc) || end < 5 {
+		return nil, invalid
+	}
+	fields := make(map[string][]byte)
+	for pos := 4; pos < end-1; {
+		kind := doc[pos]
+		index := bytes.IndexByte(doc[pos+1:end], 0)
+		if index == -1 {
+			return nil, invalid
+		}
+		name := string(doc[pos+1 : pos+1+index])
+		pos += index + 2
+		var size, skip int
+		switch kind {
+		case 0x01, 0x09, 0x11, 0x12: // double, datetime, timestamp, int64
+			size = 8
+		case 0x10: // int32
+			size = 4
+		case 0x08: // bool
+			size = 1
+		case 0x07: // objectid
+			size = 12
+		case 0x0A: // null
+		case 0x02, 0x03, 0x04, 0x05: // string, document, array, binary
+			if pos+4 > end {
+				return nil, invalid
+			}
+			size = int(binary.LittleEndian.Uint32(doc[pos:]))
+			switch kind {
+			case 0x02:
+				skip, size = 4, size-1
+				if size < 0 {
+					return nil, invalid
+				}
+			case 0x05:
+				skip = 5
+			}
+		default:
+			return nil, invalid
+		}
+		if pos+skip+size > end {
+			return nil, invalid
+		}
+		fields[name] = doc[pos+skip : pos+skip+size]
+		pos += skip + size
+		if kind == 0x02 {
+			pos++ // 字符串结尾的0
+		}
+	}
+	return fields, nil
 }
 
 func MongodbUnauth(info *common.HostInfo) (flag bool, err error) {
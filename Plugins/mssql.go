@@ -14,9 +14,23 @@ func MssqlScan(info *common.HostInfo) (tmperr error) {
 		return
 	}
 	starttime := time.Now().Unix()
+	if common.TryHostCreds(info.Host, "mssql", func(user, pass string) (bool, error) {
+		return MssqlConn(info, user, pass)
+	}) {
+		return
+	}
+	if common.TryProductCreds(info.Host, info.Ports, "mssql", func(user, pass string) (bool, error) {
+		return MssqlConn(info, user, pass)
+	}) {
+		return
+	}
 	for _, user := range common.Userdict["mssql"] {
 		for _, pass := range common.Passwords {
 			pass = strings.Replace(pass, "{user}", user, -1)
+			if info.Context().Err() != nil {
+				return tmperr
+			}
+			common.BruteLimiter.Wait()
 			flag, err := MssqlConn(info, user, pass)
 			if flag == true && err == nil {
 				return err
@@ -39,16 +53,22 @@ func MssqlScan(info *common.HostInfo) (tmperr error) {
 func MssqlConn(info *common.HostInfo, user string, pass string) (flag bool, err error) {
 	flag = false
 	Host, Port, Username, Password := info.Host, info.Ports, user, pass
-	dataSourceName := fmt.Sprintf("server=%s;user id=%s;password=%s;port=%v;encrypt=disable;timeout=%v", Host, Username, Password, Port, time.Duration(common.Timeout)*time.Second)
+	dataSourceName := fmt.Sprintf("server=%s;user id=%s;password=%s;port=%v;encrypt=disable;dial timeout=%v", Host, Username, Password, Port, common.Timeout)
+	if common.AttemptTimeout > 0 {
+		dataSourceName += fmt.Sprintf(";connection timeout=%v", common.AttemptTimeout)
+	}
 	db, err := sql.Open("mssql", dataSourceName)
 	if err == nil {
 		db.SetConnMaxLifetime(time.Duration(common.Timeout) * time.Second)
 		db.SetConnMaxIdleTime(time.Duration(common.Timeout) * time.Second)
 		db.SetMaxIdleConns(0)
 		defer db.Close()
-		err = db.Ping()
+		ctx, cancel := common.AttemptContext(info)
+		defer cancel()
+		err = db.PingContext(ctx)
 		if err == nil {
 			result := fmt.Sprintf("[+] mssql %v:%v:%v %v", Host, Port, Username, Password)
+			common.RecordCred("mssql", Host, Password)
 			common.LogSuccess(result)
 			flag = true
 		}
@@ -1,7 +1,10 @@
 package Plugins
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/shadow1ng/fscan/common"
@@ -14,10 +17,29 @@ func MysqlScan(info *common.HostInfo) (tmperr error) {
 		return
 	}
 	starttime := time.Now().Unix()
+	params := MysqlAuthParams(info)
+	if common.TryHostCreds(info.Host, "mysql", func(user, pass string) (bool, error) {
+		return MysqlConn(info, user, pass, params)
+	}) {
+		return
+	}
+	if common.TryProductCreds(info.Host, info.Ports, "mysql", func(user, pass string) (bool, error) {
+		return MysqlConn(info, user, pass, params)
+	}) {
+		return
+	}
 	for _, user := range common.Userdict["mysql"] {
 		for _, pass := range common.Passwords {
 			pass = strings.Replace(pass, "{user}", user, -1)
-			flag, err := MysqlConn(info, user, pass)
+			if info.Context().Err() != nil {
+				return tmperr
+			}
+			common.BruteLimiter.Wait()
+			flag, err := MysqlConn(info, user, pass, params)
+			if err != nil && strings.Contains(err.Error(), "insecure transport") && !strings.Contains(params, "tls=") {
+				params += "&tls=skip-verify"
+				flag, err = MysqlConn(info, user, pass, params)
+			}
 			if flag == true && err == nil {
 				return err
 			} else {
@@ -36,22 +58,112 @@ func MysqlScan(info *common.HostInfo) (tmperr error) {
 	return tmperr
 }
 
-func MysqlConn(info *common.HostInfo, user string, pass string) (flag bool, err error) {
+func MysqlConn(info *common.HostInfo, user string, pass string, params string) (flag bool, err error) {
 	flag = false
 	Host, Port, Username, Password := info.Host, info.Ports, user, pass
-	dataSourceName := fmt.Sprintf("%v:%v@tcp(%v:%v)/mysql?charset=utf8&timeout=%v", Username, Password, Host, Port, time.Duration(common.Timeout)*time.Second)
+	if common.AttemptTimeout > 0 {
+		// 认证阶段的读写也受 -attempt-timeout 限制
+		attempt := time.Duration(common.AttemptTimeout) * time.Second
+		params += fmt.Sprintf("&readTimeout=%v&writeTimeout=%v", attempt, attempt)
+	}
+	dataSourceName := fmt.Sprintf("%v:%v@tcp(%v:%v)/mysql?charset=utf8&timeout=%v%v", Username, Password, Host, Port, time.Duration(common.Timeout)*time.Second, params)
 	db, err := sql.Open("mysql", dataSourceName)
 	if err == nil {
 		db.SetConnMaxLifetime(time.Duration(common.Timeout) * time.Second)
 		db.SetConnMaxIdleTime(time.Duration(common.Timeout) * time.Second)
 		db.SetMaxIdleConns(0)
 		defer db.Close()
-		err = db.Ping()
+		ctx, cancel := common.AttemptContext(info)
+		defer cancel()
+		err = db.PingContext(ctx)
 		if err == nil {
 			result := fmt.Sprintf("[+] mysql %v:%v:%v %v", Host, Port, Username, Password)
+			common.RecordCred("mysql", Host, Password)
 			common.LogSuccess(result)
 			flag = true
 		}
 	}
 	return flag, err
 }
+
+// 读取握手包中的认证插件,为驱动选择对应的连接参数
+func MysqlAuthParams(info *common.HostInfo) string {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	version, plugin, err := MysqlHandshake(realhost)
+	if err != nil {
+		errlog := fmt.Sprintf("[-] mysql %v handshake %v", realhost, err)
+		common.LogError(errlog)
+		return ""
+	}
+	var params string
+	switch plugin {
+	case "caching_sha2_password", "sha256_password":
+		// 完整认证优先走TLS,服务端不支持TLS时驱动会获取RSA公钥
+		params = "&tls=preferred"
+	case "mysql_clear_password":
+		params = "&tls=skip-verify&allowCleartextPasswords=true"
+	case "mysql_old_password":
+		params = "&allowOldPasswords=true"
+	}
+	common.LogSuccess(fmt.Sprintf("[*] mysql %v version:%v auth:%v", realhost, version, plugin))
+	return params
+}
+
+func MysqlHandshake(realhost string) (version string, plugin string, err error) {
+	conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(common.Timeout)*time.Second)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	err = conn.SetReadDeadline(time.Now().Add(time.Duration(common.Timeout) * time.Second))
+	if err != nil {
+		return
+	}
+	packet, err := ReadBytes(conn)
+	if err != nil {
+		return
+	}
+	if len(packet) < 5 || packet[4] != 10 {
+		return "", "", errors.New("not mysql handshake v10")
+	}
+	data := packet[5:]
+	index := bytes.IndexByte(data, 0)
+	if index == -1 {
+		return "", "", errors.New("invalid handshake")
+	}
+	version = string(data[:index])
+	// conn id(4) + auth data 1(8) + filler(1)
+	pos := index + 1 + 4 + 8 + 1
+	if len(data) < pos+2 {
+		return version, "mysql_old_password", nil
+	}
+	capability := uint32(binary.LittleEndian.Uint16(data[pos:]))
+	pos += 2
+	// charset(1) + status(2)
+	pos += 3
+	if len(data) < pos+2+1+10 {
+		return version, "mysql_native_password", nil
+	}
+	capability |= uint32(binary.LittleEndian.Uint16(data[pos:])) << 16
+	pos += 2
+	authLen := int(data[pos])
+	pos += 1 + 10
+	if capability&0x00008000 != 0 {
+		// CLIENT_SECURE_CONNECTION: auth data 2
+		n := authLen - 8
+		if n < 13 {
+			n = 13
+		}
+		pos += n
+	}
+	plugin = "mysql_native_password"
+	if capability&0x00080000 != 0 && pos < len(data) {
+		// CLIENT_PLUGIN_AUTH
+		name := data[pos:]
+		if end := bytes.IndexByte(name, 0); end != -1 {
+			name = name[:end]
+		}
+		plugin = string(name)
+	}
+	return version, plugin, nil
+}
@@ -0,0 +1,145 @@
+package Plugins
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shadow1ng/fscan/common"
+)
+
+// 检测目标是否为开放代理,只尝试中转到 -proxy-target 指定的地址,未指定时不检测
+func OpenProxyScan(info *common.HostInfo) error {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	target := common.ProxyTarget
+	if target == "" {
+		return nil
+	}
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return err
+	}
+	checks := []struct {
+		name string
+		fn   func(net.Conn, string, int) error
+	}{
+		{"socks5", socks5Relay},
+		{"socks4", socks4Relay},
+		{"http", httpRelay},
+	}
+	for _, check := range checks {
+		conn, err := common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(common.Timeout)*time.Second)
+		if err != nil {
+			return err
+		}
+		conn.SetDeadline(time.Now().Add(time.Duration(common.Timeout) * time.Second))
+		err = check.fn(conn, host, port)
+		if err == nil {
+			err = relayProbe(conn, host)
+		}
+		conn.Close()
+		if err == nil {
+			result := fmt.Sprintf("[+] OpenProxy %v %v relay to %v", realhost, check.name, target)
+			common.LogSuccess(result)
+			return nil
+		}
+		errlog := fmt.Sprintf("[-] OpenProxy %v %v %v", realhost, check.name, err)
+		common.LogError(errlog)
+	}
+	return nil
+}
+
+func socks5Relay(conn net.Conn, host string, port int) error {
+	if _, err := conn.Write([]byte{0x05, 0x01, 0x00}); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := conn.Read(reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 || reply[1] != 0x00 {
+		return errors.New("no auth not accepted")
+	}
+	req := []byte{0x05, 0x01, 0x00, 0x03, byte(len(host))}
+	req = append(req, []byte(host)...)
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	reply = make([]byte, 10)
+	if _, err := conn.Read(reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 || reply[1] != 0x00 {
+		return errors.New("connect refused")
+	}
+	return nil
+}
+
+func socks4Relay(conn net.Conn, host string, port int) error {
+	// socks4a, 由代理解析域名
+	req := []byte{0x04, 0x01}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	req = append(req, 0x00, 0x00, 0x00, 0x01, 0x00)
+	req = append(req, []byte(host)...)
+	req = append(req, 0x00)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	reply := make([]byte, 8)
+	if _, err := conn.Read(reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x00 || reply[1] != 0x5a {
+		return errors.New("request rejected")
+	}
+	return nil
+}
+
+func httpRelay(conn net.Conn, host string, port int) error {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	req := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", address, address)
+	if _, err := conn.Write([]byte(req)); err != nil {
+		return err
+	}
+	reply := make([]byte, 1024)
+	n, err := conn.Read(reply)
+	if err != nil {
+		return err
+	}
+	line := string(reply[:n])
+	if index := strings.Index(line, "\r\n"); index > 0 {
+		line = line[:index]
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "HTTP/") || fields[1] != "200" {
+		return errors.New(line)
+	}
+	return nil
+}
+
+// 通过代理隧道发送一个最小的HEAD请求,确认确实完成了中转
+func relayProbe(conn net.Conn, host string) error {
+	req := fmt.Sprintf("HEAD / HTTP/1.1\r\nHost: %s\r\nUser-Agent: %s\r\nConnection: close\r\n\r\n", host, common.UserAgent)
+	if _, err := conn.Write([]byte(req)); err != nil {
+		return err
+	}
+	reply := make([]byte, 64)
+	n, err := conn.Read(reply)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(reply[:n], []byte("HTTP/")) {
+		return errors.New("relay no http response")
+	}
+	return nil
+}
@@ -14,9 +14,23 @@ func OracleScan(info *common.HostInfo) (tmperr error) {
 		return
 	}
 	starttime := time.Now().Unix()
+	if common.TryHostCreds(info.Host, "oracle", func(user, pass string) (bool, error) {
+		return OracleConn(info, user, pass)
+	}) {
+		return
+	}
+	if common.TryProductCreds(info.Host, info.Ports, "oracle", func(user, pass string) (bool, error) {
+		return OracleConn(info, user, pass)
+	}) {
+		return
+	}
 	for _, user := range common.Userdict["oracle"] {
 		for _, pass := range common.Passwords {
 			pass = strings.Replace(pass, "{user}", user, -1)
+			if info.Context().Err() != nil {
+				return tmperr
+			}
+			common.BruteLimiter.Wait()
 			flag, err := OracleConn(info, user, pass)
 			if flag == true && err == nil {
 				return err
@@ -40,15 +54,21 @@ func OracleConn(info *common.HostInfo, user string, pass string) (flag bool, err
 	flag = false
 	Host, Port, Username, Password := info.Host, info.Ports, user, pass
 	dataSourceName := fmt.Sprintf("oracle://%s:%s@%s:%s/orcl", Username, Password, Host, Port)
+	if common.AttemptTimeout > 0 {
+		dataSourceName += fmt.Sprintf("?CONNECTION%%20TIMEOUT=%v", common.AttemptTimeout)
+	}
 	db, err := sql.Open("oracle", dataSourceName)
 	if err == nil {
 		db.SetConnMaxLifetime(time.Duration(common.Timeout) * time.Second)
 		db.SetConnMaxIdleTime(time.Duration(common.Timeout) * time.Second)
 		db.SetMaxIdleConns(0)
 		defer db.Close()
-		err = db.Ping()
+		ctx, cancel := common.AttemptContext(info)
+		defer cancel()
+		err = db.PingContext(ctx)
 		if err == nil {
 			result := fmt.Sprintf("[+] oracle %v:%v:%v %v", Host, Port, Username, Password)
+			common.RecordCred("oracle", Host, Password)
 			common.LogSuccess(result)
 			flag = true
 		}
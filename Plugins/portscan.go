@@ -3,8 +3,10 @@ package Plugins
 import (
 	"fmt"
 	"github.com/shadow1ng/fscan/common"
+	"net"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -15,39 +17,59 @@ type Addr struct {
 }
 
 func PortScan(hostslist []string, ports string, timeout int64) []string {
-	var AliveAddress []string
+	return ScanPorts(hostslist, ProbePorts(ports), timeout)
+}
+
+// 解析出要探测的端口列表并输出相关提示,分批扫描时只调用一次
+func ProbePorts(ports string) []int {
 	probePorts := common.ParsePort(ports)
 	if len(probePorts) == 0 {
 		fmt.Printf("[-] parse port %s error, please check your port format\n", ports)
-		return AliveAddress
+		return nil
 	}
-	noPorts := common.ParsePort(common.NoPorts)
-	if len(noPorts) > 0 {
-		temp := map[int]struct{}{}
-		for _, port := range probePorts {
-			temp[port] = struct{}{}
-		}
-
-		for _, port := range noPorts {
-			delete(temp, port)
-		}
+	if common.NoPorts != "" {
+		probePorts = common.FilterPorts(probePorts, common.NoPorts)
+		fmt.Printf("[*] ports after -pn/-exclude-ports: %d\n", len(probePorts))
+	}
+	if common.MaxPortsPerHost > 0 && len(probePorts) > common.MaxPortsPerHost {
+		probePorts = priorityPorts(probePorts)
+		fmt.Printf("[*] max-ports-per-host %d: each host capped to %d of %d ports, skipped %d ports\n", common.MaxPortsPerHost, common.MaxPortsPerHost, len(probePorts), len(probePorts)-common.MaxPortsPerHost)
+		probePorts = probePorts[:common.MaxPortsPerHost]
+	}
+	return probePorts
+}
 
-		var newDatas []int
-		for port := range temp {
-			newDatas = append(newDatas, port)
-		}
-		probePorts = newDatas
-		sort.Ints(probePorts)
+func ScanPorts(hostslist []string, probePorts []int, timeout int64) []string {
+	var AliveAddress []string
+	if len(probePorts) == 0 {
+		return AliveAddress
 	}
 	workers := common.Threads
+	if common.DiscoveryThreads > 0 && common.DiscoveryThreads < workers {
+		workers = common.DiscoveryThreads
+	}
 	Addrs := make(chan Addr, 100)
 	results := make(chan string, 100)
 	var wg sync.WaitGroup
+	openCount := make(map[string]int)
+	tarpits := make(map[string]struct{})
+	var tarpitMutex sync.RWMutex
 
 	//接收结果
 	go func() {
 		for found := range results {
 			AliveAddress = append(AliveAddress, found)
+			if common.MaxOpenPorts > 0 {
+				host := found[:strings.LastIndex(found, ":")]
+				openCount[host]++
+				if openCount[host] == common.MaxOpenPorts+1 {
+					tarpitMutex.Lock()
+					tarpits[host] = struct{}{}
+					tarpitMutex.Unlock()
+					result := fmt.Sprintf("[+] Tarpit %s open ports > %d, suspected tarpit/honeypot, skip it", host, common.MaxOpenPorts)
+					common.LogSuccess(result)
+				}
+			}
 			wg.Done()
 		}
 	}()
@@ -56,7 +78,14 @@ func PortScan(hostslist []string, ports string, timeout int64) []string {
 	for i := 0; i < workers; i++ {
 		go func() {
 			for addr := range Addrs {
-				PortConnect(addr, results, timeout, &wg)
+				tarpitMutex.RLock()
+				_, skip := tarpits[addr.ip]
+				tarpitMutex.RUnlock()
+				if !skip {
+					PortConnect(addr, results, timeout, &wg)
+				} else {
+					common.ExplainLog(fmt.Sprintf("%s:%v", addr.ip, addr.port), "skipped, host is suspected tarpit")
+				}
 				wg.Done()
 			}
 		}()
@@ -65,6 +94,8 @@ func PortScan(hostslist []string, ports string, timeout int64) []string {
 	//添加扫描目标
 	for _, port := range probePorts {
 		for _, host := range hostslist {
+			common.WaitWindow()
+			common.WaitPause()
 			wg.Add(1)
 			Addrs <- Addr{host, port}
 		}
@@ -72,42 +103,62 @@ func PortScan(hostslist []string, ports string, timeout int64) []string {
 	wg.Wait()
 	close(Addrs)
 	close(results)
+	if len(tarpits) > 0 {
+		var newDatas []string
+		for _, address := range AliveAddress {
+			if _, ok := tarpits[address[:strings.LastIndex(address, ":")]]; !ok {
+				newDatas = append(newDatas, address)
+			}
+		}
+		AliveAddress = newDatas
+	}
 	return AliveAddress
 }
 
+// 按优先级排序端口,常见服务端口(DefaultPorts)在前,其余保持原顺序
+func priorityPorts(ports []int) []int {
+	priority := make(map[int]int)
+	for i, port := range common.ParsePort(common.DefaultPorts) {
+		priority[port] = i + 1
+	}
+	sorted := append([]int{}, ports...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		pi, pj := priority[sorted[i]], priority[sorted[j]]
+		if pi == 0 || pj == 0 {
+			return pi != 0 && pj == 0
+		}
+		return pi < pj
+	})
+	return sorted
+}
+
 func PortConnect(addr Addr, respondingHosts chan<- string, adjustedTimeout int64, wg *sync.WaitGroup) {
+	common.DiscoveryLimiter.Wait()
 	host, port := addr.ip, addr.port
-	conn, err := common.WrapperTcpWithTimeout("tcp4", fmt.Sprintf("%s:%v", host, port), time.Duration(adjustedTimeout)*time.Second)
+	network := "tcp4"
+	if strings.Contains(host, ":") {
+		network = "tcp6"
+	}
+	conn, err := common.WrapperTcpWithTimeout(network, net.JoinHostPort(host, strconv.Itoa(port)), time.Duration(adjustedTimeout)*time.Second)
 	if err == nil {
 		defer conn.Close()
 		address := host + ":" + strconv.Itoa(port)
 		result := fmt.Sprintf("%s open", address)
 		common.LogSuccess(result)
+		common.WriteOpenPort(host, port)
+		if common.Scantype == "inventory" {
+			AssetPort(host, port)
+		}
 		wg.Add(1)
 		respondingHosts <- address
+	} else {
+		common.ExplainLog(fmt.Sprintf("%s:%v", host, port), common.ExplainErr(err))
 	}
 }
 
 func NoPortScan(hostslist []string, ports string) (AliveAddress []string) {
 	probePorts := common.ParsePort(ports)
-	noPorts := common.ParsePort(common.NoPorts)
-	if len(noPorts) > 0 {
-		temp := map[int]struct{}{}
-		for _, port := range probePorts {
-			temp[port] = struct{}{}
-		}
-
-		for _, port := range noPorts {
-			delete(temp, port)
-		}
-
-		var newDatas []int
-		for port, _ := range temp {
-			newDatas = append(newDatas, port)
-		}
-		probePorts = newDatas
-		sort.Ints(probePorts)
-	}
+	probePorts = common.FilterPorts(probePorts, common.NoPorts)
 	for _, port := range probePorts {
 		for _, host := range hostslist {
 			address := host + ":" + strconv.Itoa(port)
@@ -116,3 +167,69 @@ func NoPortScan(hostslist []string, ports string) (AliveAddress []string) {
 	}
 	return
 }
+
+// -tcp-ping 预探测,任一端口连通或被拒绝(RST说明主机在线)即视为存活,全部超时的主机不再扫描
+func TcpPing(hostslist []string, ports string) []string {
+	probePorts := common.ParsePort(ports)
+	if len(probePorts) == 0 {
+		fmt.Printf("[-] parse port %s error, please check your port format\n", ports)
+		return hostslist
+	}
+	workers := common.Threads
+	if common.DiscoveryThreads > 0 && common.DiscoveryThreads < workers {
+		workers = common.DiscoveryThreads
+	}
+	hosts := make(chan string, 100)
+	alive := make(map[string]bool)
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range hosts {
+				if tcpPingHost(host, probePorts) {
+					mutex.Lock()
+					alive[host] = true
+					mutex.Unlock()
+				} else {
+					common.ExplainLog(host, "no response on tcp-ping ports "+ports+", treated as down")
+				}
+			}
+		}()
+	}
+	for _, host := range hostslist {
+		common.WaitWindow()
+		common.WaitPause()
+		hosts <- host
+	}
+	close(hosts)
+	wg.Wait()
+	var aliveHosts []string
+	for _, host := range hostslist {
+		if alive[host] {
+			aliveHosts = append(aliveHosts, host)
+		}
+	}
+	fmt.Printf("[*] tcp-ping alive hosts len is: %d, pruned %d hosts as down\n", len(aliveHosts), len(hostslist)-len(aliveHosts))
+	return aliveHosts
+}
+
+func tcpPingHost(host string, ports []int) bool {
+	network := "tcp4"
+	if strings.Contains(host, ":") {
+		network = "tcp6"
+	}
+	for _, port := range ports {
+		common.DiscoveryLimiter.Wait()
+		conn, err := common.WrapperTcpWithTimeout(network, net.JoinHostPort(host, strconv.Itoa(port)), time.Duration(common.Timeout)*time.Second)
+		if err == nil {
+			conn.Close()
+			return true
+		}
+		if strings.Contains(err.Error(), "refused") {
+			return true
+		}
+	}
+	return false
+}
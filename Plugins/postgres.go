@@ -14,9 +14,23 @@ func PostgresScan(info *common.HostInfo) (tmperr error) {
 		return
 	}
 	starttime := time.Now().Unix()
+	if common.TryHostCreds(info.Host, "postgresql", func(user, pass string) (bool, error) {
+		return PostgresConn(info, user, pass)
+	}) {
+		return
+	}
+	if common.TryProductCreds(info.Host, info.Ports, "postgresql", func(user, pass string) (bool, error) {
+		return PostgresConn(info, user, pass)
+	}) {
+		return
+	}
 	for _, user := range common.Userdict["postgresql"] {
 		for _, pass := range common.Passwords {
 			pass = strings.Replace(pass, "{user}", string(user), -1)
+			if info.Context().Err() != nil {
+				return tmperr
+			}
+			common.BruteLimiter.Wait()
 			flag, err := PostgresConn(info, user, pass)
 			if flag == true && err == nil {
 				return err
@@ -40,13 +54,19 @@ func PostgresConn(info *common.HostInfo, user string, pass string) (flag bool, e
 	flag = false
 	Host, Port, Username, Password := info.Host, info.Ports, user, pass
 	dataSourceName := fmt.Sprintf("postgres://%v:%v@%v:%v/%v?sslmode=%v", Username, Password, Host, Port, "postgres", "disable")
+	if common.AttemptTimeout > 0 {
+		dataSourceName += fmt.Sprintf("&connect_timeout=%v", common.AttemptTimeout)
+	}
 	db, err := sql.Open("postgres", dataSourceName)
 	if err == nil {
 		db.SetConnMaxLifetime(time.Duration(common.Timeout) * time.Second)
 		defer db.Close()
-		err = db.Ping()
+		ctx, cancel := common.AttemptContext(info)
+		defer cancel()
+		err = db.PingContext(ctx)
 		if err == nil {
 			result := fmt.Sprintf("[+] Postgres:%v:%v:%v %v", Host, Port, Username, Password)
+			common.RecordCred("psql", Host, Password)
 			common.LogSuccess(result)
 			flag = true
 		}
@@ -38,15 +38,31 @@ func RdpScan(info *common.HostInfo) (tmperr error) {
 	var mutex sync.Mutex
 	brlist := make(chan Brutelist)
 	port, _ := strconv.Atoi(info.Ports)
+	try := func(user, pass string) (bool, error) {
+		flag, err := RdpConn(info.Host, common.Domain, user, pass, port, common.Timeout)
+		if flag && err == nil {
+			common.RecordCred("rdp", info.Host, pass)
+			common.LogSuccess(fmt.Sprintf("[+] RDP %v:%v:%v %v", info.Host, port, user, pass))
+		}
+		return flag, err
+	}
+	if common.TryHostCreds(info.Host, "rdp", try) || common.TryProductCreds(info.Host, info.Ports, "rdp", try) {
+		return
+	}
 
 	for i := 0; i < common.BruteThread; i++ {
 		wg.Add(1)
 		go worker(info.Host, common.Domain, port, &wg, brlist, &signal, &num, all, &mutex, common.Timeout)
 	}
 
+USER:
 	for _, user := range common.Userdict["rdp"] {
 		for _, pass := range common.Passwords {
 			pass = strings.Replace(pass, "{user}", user, -1)
+			if info.Context().Err() != nil {
+				break USER
+			}
+			common.BruteLimiter.Wait()
 			brlist <- Brutelist{user, pass}
 		}
 	}
@@ -71,6 +87,7 @@ func worker(host, domain string, port int, wg *sync.WaitGroup, brlist chan Brute
 		user, pass := one.user, one.pass
 		flag, err := RdpConn(host, domain, user, pass, port, timeout)
 		if flag == true && err == nil {
+			common.RecordCred("rdp", host, pass)
 			var result string
 			if domain != "" {
 				result = fmt.Sprintf("[+] RDP %v:%v:%v\\%v %v", host, port, domain, user, pass)
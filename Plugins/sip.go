@@ -0,0 +1,186 @@
+package Plugins
+
+import (
+	"crypto/tls"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shadow1ng/fscan/common"
+)
+
+func SipScan(info *common.HostInfo) error {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	// 5060 多为UDP,先发UDP,没有SIP响应再走TCP;5061 为TLS,socks5代理只支持TCP
+	network := "tcp"
+	var reply string
+	var err error
+	if info.Ports != "5061" && common.Socks5Proxy == "" {
+		network = "udp"
+		reply, err = sipRequest(info, network, "OPTIONS", "")
+	}
+	if network == "tcp" || err != nil || !strings.HasPrefix(reply, "SIP/2.0") {
+		network = "tcp"
+		reply, err = sipRequest(info, network, "OPTIONS", "")
+	}
+	if err != nil {
+		errlog := fmt.Sprintf("[-] sip %v %v", realhost, err)
+		common.LogError(errlog)
+		return err
+	}
+	if !strings.HasPrefix(reply, "SIP/2.0") {
+		return nil
+	}
+	vendor := sipHeader(reply, "Server")
+	if vendor == "" {
+		vendor = sipHeader(reply, "User-Agent")
+	}
+	result := fmt.Sprintf("[+] SIP %v/%v %v vendor:%v", realhost, network, sipStatus(reply), vendor)
+	if allow := sipHeader(reply, "Allow"); allow != "" {
+		result += " allow:" + allow
+	}
+	common.LogSuccess(result)
+	if common.SipExt != "" {
+		SipEnumExt(info, network)
+	}
+	return nil
+}
+
+// 通过REGISTER响应差异枚举分机号。很多服务器对不存在的分机也回401/407,
+// 所以先用一个随机的、不可能存在的分机号取基准响应,只报告状态码与基准不同的分机
+func SipEnumExt(info *common.HostInfo, network string) {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	exts, err := parseExtRange(common.SipExt)
+	if err != nil {
+		common.LogError(fmt.Sprintf("[-] sip-ext %v %v", common.SipExt, err))
+		return
+	}
+	// 分机枚举动静较大,未指定 -brute-rate 时默认每秒5次
+	limiter := common.BruteLimiter
+	if limiter == nil {
+		limiter = common.NewRateLimiter(5)
+	}
+	invalid := fmt.Sprintf("9%015d", rand.Int63n(1e15))
+	reply, err := sipRequest(info, network, "REGISTER", invalid)
+	if err != nil {
+		common.LogError(fmt.Sprintf("[-] sip-ext %v baseline %v", realhost, err))
+		return
+	}
+	baseline := sipCode(reply)
+	var found []string
+	for _, ext := range exts {
+		common.WaitWindow()
+		common.WaitPause()
+		limiter.Wait()
+		reply, err := sipRequest(info, network, "REGISTER", ext)
+		if err == nil {
+			if code := sipCode(reply); code != "" && code != baseline {
+				found = append(found, ext+"("+code+")")
+			}
+		}
+	}
+	if len(found) > 0 {
+		result := fmt.Sprintf("[+] SIP %v extensions:%v", realhost, strings.Join(found, ","))
+		common.LogSuccess(result)
+	}
+}
+
+func sipRequest(info *common.HostInfo, network string, method string, ext string) (string, error) {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	var conn net.Conn
+	var err error
+	transport := strings.ToUpper(network)
+	if network == "udp" {
+		conn, err = net.DialTimeout("udp", common.JoinAddr(realhost), time.Duration(common.Timeout)*time.Second)
+	} else {
+		conn, err = common.WrapperTcpWithTimeout("tcp", realhost, time.Duration(common.Timeout)*time.Second)
+	}
+	if err != nil {
+		return "", err
+	}
+	if network == "tcp" && info.Ports == "5061" {
+		conn = tls.Client(conn, &tls.Config{MinVersion: tls.VersionTLS10, InsecureSkipVerify: true})
+		transport = "TLS"
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Duration(common.Timeout) * time.Second))
+	local := conn.LocalAddr().String()
+	localIP, _, _ := net.SplitHostPort(local)
+	user := "fscan"
+	uri := fmt.Sprintf("sip:%s", realhost)
+	if ext != "" {
+		user = ext
+	}
+	tag := fmt.Sprintf("%d", rand.Int31())
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf("%s %s SIP/2.0\r\n", method, uri))
+	buf.WriteString(fmt.Sprintf("Via: SIP/2.0/%s %s;branch=z9hG4bK%s;rport\r\n", transport, local, tag))
+	buf.WriteString("Max-Forwards: 70\r\n")
+	buf.WriteString(fmt.Sprintf("From: <sip:%s@%s>;tag=%s\r\n", user, info.Host, tag))
+	buf.WriteString(fmt.Sprintf("To: <sip:%s@%s>\r\n", user, info.Host))
+	buf.WriteString(fmt.Sprintf("Call-ID: %s@%s\r\n", tag, localIP))
+	buf.WriteString(fmt.Sprintf("CSeq: 1 %s\r\n", method))
+	buf.WriteString(fmt.Sprintf("Contact: <sip:%s@%s>\r\n", user, local))
+	buf.WriteString("User-Agent: fscan\r\n")
+	buf.WriteString("Accept: application/sdp\r\n")
+	buf.WriteString("Content-Length: 0\r\n\r\n")
+	if _, err = conn.Write([]byte(buf.String())); err != nil {
+		return "", err
+	}
+	reply, err := ReadBytes(conn)
+	return string(reply), err
+}
+
+func sipStatus(reply string) string {
+	line := strings.SplitN(reply, "\r\n", 2)[0]
+	return strings.TrimSpace(strings.TrimPrefix(line, "SIP/2.0"))
+}
+
+// 响应的状态码,如 401
+func sipCode(reply string) string {
+	return strings.SplitN(sipStatus(reply), " ", 2)[0]
+}
+
+func sipHeader(reply string, name string) string {
+	for _, line := range strings.Split(reply, "\r\n") {
+		index := strings.Index(line, ":")
+		if index > 0 && strings.EqualFold(strings.TrimSpace(line[:index]), name) {
+			return strings.TrimSpace(line[index+1:])
+		}
+	}
+	return ""
+}
+
+// 分机枚举的最大数量
+const maxSipExt = 100000
+
+// 解析分机范围 100-120 或 100,101,200,分机号不受端口的65535上限限制
+func parseExtRange(spec string) (result []string, err error) {
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		bounds := strings.SplitN(item, "-", 2)
+		start, err := strconv.ParseUint(bounds[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extension %s", item)
+		}
+		end := start
+		if len(bounds) == 2 {
+			if end, err = strconv.ParseUint(bounds[1], 10, 64); err != nil || end < start {
+				return nil, fmt.Errorf("invalid extension range %s", item)
+			}
+		}
+		if end-start >= maxSipExt || len(result)+int(end-start)+1 > maxSipExt {
+			return nil, fmt.Errorf("more than %d extensions", maxSipExt)
+		}
+		for ext := start; ext <= end; ext++ {
+			result = append(result, strconv.FormatUint(ext, 10))
+		}
+	}
+	return common.RemoveDuplicate(result), nil
+}
@@ -14,11 +14,27 @@ func SmbScan(info *common.HostInfo) (tmperr error) {
 		return nil
 	}
 	starttime := time.Now().Unix()
+	try := func(user, pass string) (bool, error) {
+		flag, err := doWithTimeOut(info, user, pass)
+		if flag && err == nil {
+			common.RecordCred("smb", info.Host, pass)
+			common.LogSuccess(fmt.Sprintf("[+] SMB %v:%v:%v %v", info.Host, info.Ports, user, pass))
+		}
+		return flag, err
+	}
+	if common.TryHostCreds(info.Host, "smb", try) || common.TryProductCreds(info.Host, info.Ports, "smb", try) {
+		return
+	}
 	for _, user := range common.Userdict["smb"] {
 		for _, pass := range common.Passwords {
 			pass = strings.Replace(pass, "{user}", user, -1)
+			if info.Context().Err() != nil {
+				return tmperr
+			}
+			common.BruteLimiter.Wait()
 			flag, err := doWithTimeOut(info, user, pass)
 			if flag == true && err == nil {
+				common.RecordCred("smb", info.Host, pass)
 				var result string
 				if common.Domain != "" {
 					result = fmt.Sprintf("[+] SMB %v:%v:%v\\%v %v", info.Host, info.Ports, common.Domain, user, pass)
@@ -22,6 +22,10 @@ func SmbScan2(info *common.HostInfo) (tmperr error) {
 	PASS:
 		for _, pass := range common.Passwords {
 			pass = strings.Replace(pass, "{user}", user, -1)
+			if info.Context().Err() != nil {
+				return tmperr
+			}
+			common.BruteLimiter.Wait()
 			flag, err, flag2 := Smb2Con(info, user, pass, hash, hasprint)
 			if flag2 {
 				hasprint = true
@@ -38,6 +42,7 @@ func SmbScan2(info *common.HostInfo) (tmperr error) {
 				} else {
 					result += pass
 				}
+				common.RecordCred("smb2", info.Host, pass)
 				common.LogSuccess(result)
 				return err
 			} else {
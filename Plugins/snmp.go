@@ -0,0 +1,216 @@
+package Plugins
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/shadow1ng/fscan/common"
+)
+
+var (
+	snmpSysDescr   = []int{1, 3, 6, 1, 2, 1, 1, 1, 0}
+	snmpSysContact = []int{1, 3, 6, 1, 2, 1, 1, 4, 0}
+)
+
+// snmp走udp,tcp端口扫描发现不了,需要 -m snmp 直接探测161
+func SnmpScan(info *common.HostInfo) error {
+	realhost := fmt.Sprintf("%s:%v", info.Host, info.Ports)
+	for _, community := range strings.Split(common.SnmpCommunity, ",") {
+		community = strings.TrimSpace(community)
+		if community == "" {
+			continue
+		}
+		common.BruteLimiter.Wait()
+		_, _, descr, err := snmpRequest(realhost, community, 0xa0, snmpSysDescr, 0, nil)
+		if err != nil {
+			errlog := fmt.Sprintf("[-] snmp %v %v %v", realhost, community, err)
+			common.LogError(errlog)
+			continue
+		}
+		common.RecordCred("snmp", info.Host, community)
+		if snmpWritable(realhost, community) {
+			result := fmt.Sprintf("[+] SNMP %v community:%v writable [critical] %v", realhost, community, snmpPrintable(descr))
+			common.LogSuccess(result)
+		} else {
+			result := fmt.Sprintf("[+] SNMP %v community:%v read-only %v", realhost, community, snmpPrintable(descr))
+			common.LogSuccess(result)
+		}
+	}
+	return nil
+}
+
+// 读出sysContact后原值写回,设备状态不变;写成功即说明团体字可写
+func snmpWritable(realhost, community string) bool {
+	tag, status, value, err := snmpRequest(realhost, community, 0xa0, snmpSysContact, 0, nil)
+	if err != nil || status != 0 || tag != 0x04 {
+		return false
+	}
+	_, status, _, err = snmpRequest(realhost, community, 0xa3, snmpSysContact, tag, value)
+	return err == nil && status == 0
+}
+
+// 发送v2c get(0xa0)/set(0xa3)请求,返回第一个变量的类型、error-status和值
+func snmpRequest(realhost, community string, pduType byte, oid []int, valueTag byte, value []byte) (byte, int, []byte, error) {
+	conn, err := net.DialTimeout("udp", realhost, time.Duration(common.Timeout)*time.Second)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Duration(common.Timeout) * time.Second))
+	if pduType == 0xa0 {
+		valueTag = 0x05 // NULL
+	}
+	requestID := rand.Int31()
+	varbind := berTLV(0x30, append(berTLV(0x06, berOID(oid)), berTLV(valueTag, value)...))
+	pdu := berInt(int(requestID))
+	pdu = append(pdu, berInt(0)...)
+	pdu = append(pdu, berInt(0)...)
+	pdu = append(pdu, berTLV(0x30, varbind)...)
+	msg := berInt(1) // v2c
+	msg = append(msg, berTLV(0x04, []byte(community))...)
+	msg = append(msg, berTLV(pduType, pdu)...)
+	if _, err = conn.Write(berTLV(0x30, msg)); err != nil {
+		return 0, 0, nil, err
+	}
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return snmpParse(buf[:n], int(requestID))
+}
+
+func snmpParse(data []byte, requestID int) (byte, int, []byte, error) {
+	errInvalid := errors.New("invalid snmp response")
+	_, msg, _, ok := berRead(data)
+	if !ok {
+		return 0, 0, nil, errInvalid
+	}
+	var fields [][]byte
+	var tag byte
+	for i := 0; i < 3; i++ {
+		var field []byte
+		if tag, field, msg, ok = berRead(msg); !ok {
+			return 0, 0, nil, errInvalid
+		}
+		fields = append(fields, field)
+	}
+	if tag != 0xa2 {
+		return 0, 0, nil, errInvalid
+	}
+	pdu := fields[2]
+	var items [][]byte
+	for i := 0; i < 4; i++ {
+		var item []byte
+		if _, item, pdu, ok = berRead(pdu); !ok {
+			return 0, 0, nil, errInvalid
+		}
+		items = append(items, item)
+	}
+	if berToInt(items[0]) != requestID {
+		return 0, 0, nil, errInvalid
+	}
+	status := berToInt(items[1])
+	_, varbind, _, ok := berRead(items[3])
+	if !ok {
+		return 0, status, nil, nil
+	}
+	if _, _, varbind, ok = berRead(varbind); !ok {
+		return 0, status, nil, nil
+	}
+	tag, value, _, ok := berRead(varbind)
+	if !ok {
+		return 0, status, nil, nil
+	}
+	return tag, status, value, nil
+}
+
+func berTLV(tag byte, content []byte) []byte {
+	out := []byte{tag}
+	length := len(content)
+	switch {
+	case length < 0x80:
+		out = append(out, byte(length))
+	case length < 0x100:
+		out = append(out, 0x81, byte(length))
+	default:
+		out = append(out, 0x82, byte(length>>8), byte(length))
+	}
+	return append(out, content...)
+}
+
+func berInt(n int) []byte {
+	var content []byte
+	for {
+		content = append([]byte{byte(n)}, content...)
+		if n >= -128 && n < 128 {
+			break
+		}
+		n >>= 8
+	}
+	return berTLV(0x02, content)
+}
+
+func berOID(oid []int) []byte {
+	out := []byte{byte(oid[0]*40 + oid[1])}
+	for _, sub := range oid[2:] {
+		var enc []byte
+		enc = append(enc, byte(sub&0x7f))
+		for sub >>= 7; sub > 0; sub >>= 7 {
+			enc = append([]byte{byte(sub&0x7f | 0x80)}, enc...)
+		}
+		out = append(out, enc...)
+	}
+	return out
+}
+
+// 读取一个TLV,返回类型、内容和剩余数据
+func berRead(data []byte) (byte, []byte, []byte, bool) {
+	if len(data) < 2 {
+		return 0, nil, nil, false
+	}
+	tag, length, offset := data[0], int(data[1]), 2
+	if length&0x80 != 0 {
+		count := length & 0x7f
+		if count == 0 || count > 2 || len(data) < 2+count {
+			return 0, nil, nil, false
+		}
+		length = 0
+		for _, b := range data[2 : 2+count] {
+			length = length<<8 | int(b)
+		}
+		offset += count
+	}
+	if len(data) < offset+length {
+		return 0, nil, nil, false
+	}
+	return tag, data[offset : offset+length], data[offset+length:], true
+}
+
+func berToInt(content []byte) int {
+	n := 0
+	for i, b := range content {
+		if i == 0 && b&0x80 != 0 {
+			n = -1
+		}
+		n = n<<8 | int(b)
+	}
+	return n
+}
+
+func snmpPrintable(value []byte) string {
+	s := strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, string(value))
+	if len(s) > 100 {
+		s = s[:100]
+	}
+	return strings.TrimSpace(s)
+}
@@ -16,9 +16,23 @@ func SshScan(info *common.HostInfo) (tmperr error) {
 		return
 	}
 	starttime := time.Now().Unix()
+	if common.TryHostCreds(info.Host, "ssh", func(user, pass string) (bool, error) {
+		return SshConn(info, user, pass)
+	}) {
+		return
+	}
+	if common.TryProductCreds(info.Host, info.Ports, "ssh", func(user, pass string) (bool, error) {
+		return SshConn(info, user, pass)
+	}) {
+		return
+	}
 	for _, user := range common.Userdict["ssh"] {
 		for _, pass := range common.Passwords {
 			pass = strings.Replace(pass, "{user}", user, -1)
+			if info.Context().Err() != nil {
+				return tmperr
+			}
+			common.BruteLimiter.Wait()
 			flag, err := SshConn(info, user, pass)
 			if flag == true && err == nil {
 				return err
@@ -75,6 +89,7 @@ func SshConn(info *common.HostInfo, user string, pass string) (flag bool, err er
 		if err == nil {
 			defer session.Close()
 			flag = true
+			common.RecordCred("ssh", Host, Password)
 			var result string
 			if common.Command != "" {
 				combo, _ := session.CombinedOutput(common.Command)
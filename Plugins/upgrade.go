@@ -0,0 +1,88 @@
+package Plugins
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shadow1ng/fscan/common"
+)
+
+// 探测web服务支持的协议升级: https通过ALPN协商h2,http通过Upgrade头测试h2c,
+// 两者都测试websocket握手,收到101后立即断开,不发送任何帧
+func CheckUpgrades(u *url.URL) []string {
+	var protocols []string
+	if u.Scheme == "https" {
+		if upgradeALPN(u) {
+			protocols = append(protocols, "h2")
+		}
+	} else if upgradeRequest(u, "Connection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAMAAABkAARAAAAAAAIAAAAA\r\n") {
+		protocols = append(protocols, "h2c")
+	}
+	key := make([]byte, 16)
+	rand.Read(key)
+	if upgradeRequest(u, "Connection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: "+base64.StdEncoding.EncodeToString(key)+"\r\n") {
+		protocols = append(protocols, "websocket")
+	}
+	return protocols
+}
+
+func upgradeDial(u *url.URL, nextProtos []string) (net.Conn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "https" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+	timeout := time.Duration(common.WebTimeout) * time.Second
+	conn, err := common.WrapperTcpWithTimeout("tcp", host, timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if u.Scheme != "https" {
+		return conn, nil
+	}
+	tlsConn := tls.Client(conn, &tls.Config{MinVersion: tls.VersionTLS10, InsecureSkipVerify: true, ServerName: u.Hostname(), NextProtos: nextProtos})
+	if err = tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func upgradeALPN(u *url.URL) bool {
+	conn, err := upgradeDial(u, []string{"h2", "http/1.1"})
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	return conn.(*tls.Conn).ConnectionState().NegotiatedProtocol == "h2"
+}
+
+func upgradeRequest(u *url.URL, headers string) bool {
+	conn, err := upgradeDial(u, []string{"http/1.1"})
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	path := u.RequestURI()
+	request := fmt.Sprintf("GET %s HTTP/1.1\r\nHost: %s\r\nUser-Agent: %s\r\n%s\r\n", path, u.Host, common.UserAgent, headers)
+	if _, err = conn.Write([]byte(request)); err != nil {
+		return false
+	}
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(status)
+	return len(fields) > 1 && fields[1] == "101"
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -23,8 +24,19 @@ func WebTitle(info *common.HostInfo) error {
 		WebScan.WebScan(info)
 		return nil
 	}
+	fromPort := info.Url == ""
 	err, CheckData := GOWebTitle(info)
 	info.Infostr = WebScan.InfoCheck(info.Url, &CheckData)
+	common.SetFingerprint(info.Host, info.Ports, strings.Join(info.Infostr, " "))
+	if err == nil && IsContain(hadoopPorts, info.Ports) {
+		HadoopScan(info)
+	}
+	if err == nil && isJupyter(info, CheckData) {
+		JupyterScan(info)
+	}
+	if err == nil && isJavaServer(info, CheckData) {
+		JavaManagerScan(info)
+	}
 
 	if !common.NoPoc && err == nil {
 		WebScan.WebScan(info)
@@ -32,6 +44,9 @@ func WebTitle(info *common.HostInfo) error {
 		errlog := fmt.Sprintf("[-] webtitle %v %v", info.Url, err)
 		common.LogError(errlog)
 	}
+	if err != nil && fromPort && len(CheckData) == 0 {
+		UnknownService(info)
+	}
 	return err
 }
 func GOWebTitle(info *common.HostInfo) (err error, CheckData []WebScan.CheckDatas) {
@@ -60,9 +75,10 @@ func GOWebTitle(info *common.HostInfo) (err error, CheckData []WebScan.CheckData
 	}
 
 	//有跳转
-	if strings.Contains(result, "://") {
+	if strings.Contains(result, "://") && redirectInScope(info.Url, result) {
+		from := info.Url
 		info.Url = result
-		err, result, CheckData = geturl(info, 3, CheckData)
+		err, result, CheckData = geturl(info, 3, CheckData, from)
 		if err != nil {
 			return
 		}
@@ -72,9 +88,10 @@ func GOWebTitle(info *common.HostInfo) (err error, CheckData []WebScan.CheckData
 		info.Url = strings.Replace(info.Url, "http://", "https://", 1)
 		err, result, CheckData = geturl(info, 1, CheckData)
 		//有跳转
-		if strings.Contains(result, "://") {
+		if strings.Contains(result, "://") && redirectInScope(info.Url, result) {
+			from := info.Url
 			info.Url = result
-			err, _, CheckData = geturl(info, 3, CheckData)
+			err, _, CheckData = geturl(info, 3, CheckData, from)
 			if err != nil {
 				return
 			}
@@ -88,10 +105,18 @@ func GOWebTitle(info *common.HostInfo) (err error, CheckData []WebScan.CheckData
 	return
 }
 
-func geturl(info *common.HostInfo, flag int, CheckData []WebScan.CheckDatas) (error, string, []WebScan.CheckDatas) {
+func redirectInScope(from, to string) bool {
+	if common.RedirectInScope(from, to) {
+		return true
+	}
+	common.LogSuccess(fmt.Sprintf("[*] Redirect out of scope %v -> %v", from, to))
+	return false
+}
+
+func geturl(info *common.HostInfo, flag int, CheckData []WebScan.CheckDatas, via ...string) (error, string, []WebScan.CheckDatas) {
 	//flag 1 first try
 	//flag 2 /favicon.ico
-	//flag 3 302
+	//flag 3 302, via 为之前经过的url
 	//flag 4 400 -> https
 
 	Url := info.Url
@@ -132,6 +157,9 @@ func geturl(info *common.HostInfo, flag int, CheckData []WebScan.CheckDatas) (er
 	}
 
 	defer resp.Body.Close()
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		CheckCert(fmt.Sprintf("https://%s", resp.Request.URL.Host), resp.TLS.PeerCertificates[0])
+	}
 	var title string
 	body, err := getRespBody(resp)
 	if err != nil {
@@ -144,6 +172,12 @@ func geturl(info *common.HostInfo, flag int, CheckData []WebScan.CheckDatas) (er
 			body, _ = simplifiedchinese.GBK.NewDecoder().Bytes(body)
 		}
 		title = gettitle(body)
+		CheckDirListing(resp.Request.URL.String(), body)
+		CheckSecrets(resp.Request.URL.String(), body)
+		CheckBasicAuth(resp.Request.URL.String(), resp, body)
+		if provider := common.CDNHeader(resp.Header); provider != "" {
+			common.LogSuccess(fmt.Sprintf("[*] CDN %v provider:%v", resp.Request.URL, provider))
+		}
 		length := resp.Header.Get("Content-Length")
 		if length == "" {
 			length = fmt.Sprintf("%v", len(body))
@@ -156,6 +190,14 @@ func geturl(info *common.HostInfo, flag int, CheckData []WebScan.CheckDatas) (er
 		if reurl != "" {
 			result += fmt.Sprintf(" 跳转url: %s", reurl)
 		}
+		if chain := redirectChain(resp, via); len(chain) > 1 {
+			result += fmt.Sprintf(" 跳转链: %s", strings.Join(chain, " -> "))
+		}
+		if common.WebProto {
+			if protocols := CheckUpgrades(resp.Request.URL); len(protocols) > 0 {
+				result += " proto:" + strings.Join(protocols, ",")
+			}
+		}
 		common.LogSuccess(result)
 	}
 	if reurl != "" {
@@ -167,6 +209,19 @@ func geturl(info *common.HostInfo, flag int, CheckData []WebScan.CheckDatas) (er
 	return nil, "", CheckData
 }
 
+// 通过 resp.Request.Response 回溯本次请求经过的完整跳转链
+func redirectChain(resp *http.Response, via []string) []string {
+	var chain []string
+	for req := resp.Request; req != nil; {
+		chain = append([]string{req.URL.String()}, chain...)
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+	return append(via, chain...)
+}
+
 func getRespBody(oResp *http.Response) ([]byte, error) {
 	var body []byte
 	if oResp.Header.Get("Content-Encoding") == "gzip" {
@@ -231,7 +286,11 @@ func GetProtocol(host string, Timeout int64) (protocol string) {
 	if err != nil {
 		return
 	}
-	conn := tls.Client(socksconn, &tls.Config{MinVersion: tls.VersionTLS10, InsecureSkipVerify: true})
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS10, InsecureSkipVerify: true}
+	if hostname, _, err := net.SplitHostPort(host); err == nil && net.ParseIP(hostname) == nil {
+		tlsConfig.ServerName = hostname
+	}
+	conn := tls.Client(socksconn, tlsConfig)
 	defer func() {
 		if conn != nil {
 			defer func() {
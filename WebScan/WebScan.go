@@ -17,6 +17,14 @@ var Pocs embed.FS
 var once sync.Once
 var AllPocs []*lib.Poc
 
+// -scopes 的每个范围按自己的 -pocpath 等参数重新加载poc
+func init() {
+	common.OnScopeReset(func() {
+		once = sync.Once{}
+		AllPocs = nil
+	})
+}
+
 func WebScan(info *common.HostInfo) {
 	once.Do(initpoc)
 	var pocinfo = common.Pocinfo
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -130,6 +131,42 @@ func executePoc(oReq *http.Request, p *Poc) (bool, error, string) {
 		req.Url.Path = strings.ReplaceAll(req.Url.Path, " ", "%20")
 		//req.Url.Path = strings.ReplaceAll(req.Url.Path, "+", "%20")
 
+		if rule.Matcher == "time-based" {
+			return timeBasedMatch(rule, func(sleep int) (time.Duration, error) {
+				value := strconv.Itoa(sleep)
+				path := strings.ReplaceAll(req.Url.Path, "{{sleep}}", value)
+				body := strings.ReplaceAll(rule.Body, "{{sleep}}", value)
+				timeRequest, err := http.NewRequest(rule.Method, fmt.Sprintf("%s://%s%s", req.Url.Scheme, req.Url.Host, path), strings.NewReader(body))
+				if err != nil {
+					return 0, err
+				}
+				timeRequest.Header = oReq.Header.Clone()
+				for k, v := range Headers {
+					timeRequest.Header.Set(k, strings.ReplaceAll(v, "{{sleep}}", value))
+				}
+				start := time.Now()
+				_, err = DoRequest(timeRequest, rule.FollowRedirects)
+				return time.Since(start), err
+			})
+		}
+
+		if rule.Matcher == "oob" {
+			return oobMatch(rule, oReq.URL.String(), func(payload string) error {
+				path := strings.ReplaceAll(req.Url.Path, "{{oob}}", payload)
+				body := strings.ReplaceAll(rule.Body, "{{oob}}", payload)
+				oobRequest, err := http.NewRequest(rule.Method, fmt.Sprintf("%s://%s%s", req.Url.Scheme, req.Url.Host, path), strings.NewReader(body))
+				if err != nil {
+					return err
+				}
+				oobRequest.Header = oReq.Header.Clone()
+				for k, v := range Headers {
+					oobRequest.Header.Set(k, strings.ReplaceAll(v, "{{oob}}", payload))
+				}
+				_, err = DoRequest(oobRequest, rule.FollowRedirects)
+				return err
+			})
+		}
+
 		newRequest, err := http.NewRequest(rule.Method, fmt.Sprintf("%s://%s%s", req.Url.Scheme, req.Url.Host, string([]rune(req.Url.Path))), strings.NewReader(rule.Body))
 		if err != nil {
 			//fmt.Println("[-] newRequest error: ",err)
@@ -494,6 +531,10 @@ func cloneRules(tags Rules) Rules {
 	cloneTags.Search = tags.Search
 	cloneTags.FollowRedirects = tags.FollowRedirects
 	cloneTags.Expression = tags.Expression
+	cloneTags.Matcher = tags.Matcher
+	cloneTags.Delay = tags.Delay
+	cloneTags.Samples = tags.Samples
+	cloneTags.Threshold = tags.Threshold
 	cloneTags.Headers = cloneMap(tags.Headers)
 	return cloneTags
 }
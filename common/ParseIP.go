@@ -2,105 +2,1071 @@ package common
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"math/rand"
+	"hash/fnv"
+	"io"
 	"net"
+	"net/url"
 	"os"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
-var ParseIPErr = errors.New(" host parsing error\n" +
-	"format: \n" +
-	"192.168.1.1\n" +
+var ParseIPErr error = &msgError{id: "parse_ip_err", suffix: "192.168.1.1\n" +
 	"192.168.1.1/8\n" +
 	"192.168.1.1/16\n" +
 	"192.168.1.1/24\n" +
 	"192.168.1.1,192.168.1.2\n" +
 	"192.168.1.1-192.168.255.255\n" +
-	"192.168.1.1-255")
+	"192.168.1.1-255\n" +
+	"private:192 (192.168.0.0/16), private:172, private:10\n" +
+	"https://192.168.1.1:8443/admin\n" +
+	"local, local:eth0\n" +
+	"192.168.1.0/24!192.168.1.1,192.168.1.254"}
 
-func ParseIP(host string, filename string, nohosts ...string) (hosts []string, err error) {
-	if filename == "" && strings.Contains(host, ":") {
-		//192.168.0.0/16:80
-		hostport := strings.Split(host, ":")
-		if len(hostport) == 2 {
-			host = hostport[0]
-			hosts = ParseIPs(host)
-			Ports = hostport[1]
+// 只给了 -hf,文件能打开但没有一行能解析成目标
+var EmptyHostFileErr error = &msgError{id: "empty_host_file"}
+
+var ExcludeAllErr error = &msgError{id: "exclude_all"}
+
+// 单个ip段/cidr展开的最大地址数,超过则报错不展开,需要时可调大
+var MaxIPRange = 1 << 24
+
+func (r *parseRun) checkIPRange(ip string, count uint64) bool {
+	if count > uint64(r.maxRange) {
+		r.log(Msg("ip_range_limit", ip, count, r.maxRange))
+		return false
+	}
+	return true
+}
+
+// host:ports 展开后的最大目标数
+var MaxHostPort = 1 << 20
+
+// 单个目标文件展开后的主机总数上限(去重前,host:port 按组合数计),避免文件中写了大量大网段时撑爆内存
+var MaxHostsFromFile = 1 << 24
+
+var MaxHostsFileErr = errors.New("too many hosts in host file")
+
+func ParseIP(host string, filename string, nohosts ...string) ([]string, error) {
+	res, err := parseDefault(context.Background(), host, filename, nohosts, false)
+	return res.Hosts, err
+}
+
+// 可取消的 ParseIP,展开大范围和读文件时定期检查ctx,取消后丢弃已解析的部分并返回 ctx.Err()
+func ParseIPContext(ctx context.Context, host string, filename string, nohosts ...string) ([]string, error) {
+	res, err := parseDefault(ctx, host, filename, nohosts, false)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return res.Hosts, err
+}
+
+// 解析后的单个目标,Port 为0表示按 -p 扫描,Source 为来源标签,逗号分隔:
+// cli(-h) 或 file:文件名(-hf),由网段展开的加 cidr:10.0.0.0/24 或 range:10.0.0.1-20,域名解析得到的加 resolve:域名,
+// 本机网段加 local 或 local:网卡名
+type Target struct {
+	IP       string   `json:"ip"`
+	Port     int      `json:"port,omitempty"`
+	Source   string   `json:"source"`
+	URL      string   `json:"url,omitempty"`      // 以url形式给出的目标,保留协议和路径
+	Hostname string   `json:"hostname,omitempty"` // -ptr 或 -dns-resolve 得到的主机名
+	Names    []string `json:"names,omitempty"`    // 去重时合并到该ip的其他写法,如 -resolve 映射到同一ip的域名
+}
+
+// 去重时被合并的域名,ip -> 域名列表
+var HostAliases = make(map[string][]string)
+
+// 域名按 -resolve 映射或 -dns-resolve 解析得到的ip再比较,其余原样。
+// names 为本次解析中 ip -> 域名 的记录(尚未并入 ResolvedNames),一个域名对应多个ip时取最小的
+func canonicalHost(host string, names map[string]string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	if ip, ok := ResolveMap[strings.ToLower(host)]; ok {
+		return ip
+	}
+	var ips []string
+	for ip, name := range names {
+		if strings.EqualFold(name, host) {
+			ips = append(ips, ip)
 		}
-	} else {
-		hosts = ParseIPs(host)
-		if filename != "" {
-			var filehost []string
-			filehost, _ = Readipfile(filename)
-			hosts = append(hosts, filehost...)
+	}
+	resolveMutex.RLock()
+	for ip, name := range ResolvedNames {
+		if strings.EqualFold(name, host) {
+			ips = append(ips, ip)
 		}
 	}
+	resolveMutex.RUnlock()
+	if len(ips) > 0 {
+		return SortIPs(ips)[0]
+	}
+	return host
+}
 
-	if len(nohosts) > 0 {
-		nohost := nohosts[0]
-		if nohost != "" {
-			nohosts := ParseIPs(nohost)
-			if len(nohosts) > 0 {
-				temp := map[string]struct{}{}
-				for _, host := range hosts {
-					temp[host] = struct{}{}
+// 按 规范化ip+端口 去重,同一台机器以ip和域名两种写法出现时只保留ip,域名记入 Names
+func dedupTargets(targets []Target, names map[string]string) []Target {
+	index := make(map[string]int, len(targets))
+	var result []Target
+	for _, target := range targets {
+		canonical := canonicalHost(target.IP, names)
+		key := canonical + ":" + strconv.Itoa(target.Port)
+		i, ok := index[key]
+		if !ok {
+			if canonical != target.IP {
+				target.Names = append(target.Names, target.IP)
+				target.IP = canonical
+			}
+			index[key] = len(result)
+			result = append(result, target)
+			continue
+		}
+		if canonical == target.IP {
+			continue
+		}
+		found := false
+		for _, name := range result[i].Names {
+			if name == target.IP {
+				found = true
+			}
+		}
+		if !found {
+			result[i].Names = append(result[i].Names, target.IP)
+		}
+	}
+	return result
+}
+
+// 字符串列表版本,合并的域名记入 Aliases,结果中仍以 [host:域名] 标注
+func (r *parseRun) dedupHosts(hosts []string) []string {
+	if len(ResolveMap) == 0 && !DnsResolve {
+		return hosts
+	}
+	targets := make([]Target, len(hosts))
+	for i, host := range hosts {
+		targets[i].IP = host
+	}
+	targets = dedupTargets(targets, r.res.Names)
+	result := make([]string, len(targets))
+	for i, target := range targets {
+		result[i] = target.IP
+		if len(target.Names) > 0 {
+			r.res.Aliases[target.IP] = target.Names
+			if _, ok := r.res.Names[target.IP]; !ok {
+				r.res.Names[target.IP] = target.Names[0]
+			}
+			for _, name := range target.Names {
+				if tag := r.tagOf(name); tag != "" && r.tagOf(target.IP) == "" {
+					r.setTag(target.IP, tag)
 				}
+			}
+		}
+	}
+	return result
+}
+
+// 与 ParseIP 相同的解析流程,结果带上来源,host:port 目标也一并返回(同时仍写入 HostPort)
+func ParseTargets(host string, filename string, nohosts ...string) ([]Target, error) {
+	res, err := parseDefault(context.Background(), host, filename, nohosts, true)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]Target, 0, len(res.Hosts)+len(res.HostPort))
+	for _, ip := range res.Hosts {
+		target := Target{IP: ip, Source: res.Sources[ip], Names: res.Aliases[ip]}
+		// 只以 -resolve 域名形式出现的主机,去重后换成了ip
+		if target.Source == "" && len(target.Names) > 0 {
+			target.Source = res.Sources[target.Names[0]] + ",resolve:" + target.Names[0]
+		}
+		targets = append(targets, target)
+	}
+	// -h 为 host:ports 时不读文件,其余 HostPort 都来自文件
+	source := "file:" + filename
+	if filename == "" {
+		source = "cli"
+	}
+	for _, target := range res.HostPort {
+		index := strings.LastIndex(target, ":")
+		port, _ := strconv.Atoi(target[index+1:])
+		targets = append(targets, Target{IP: strings.Trim(target[:index], "[]"), Port: port, Source: source, URL: res.URLs[target]})
+	}
+	return targets, nil
+}
+
+// -targets-json 导出解析结果,- 为标准输出
+func WriteTargets(filename string, targets []Target) error {
+	data, err := json.MarshalIndent(targets, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if filename == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(filename, data, 0666); err != nil {
+		return err
+	}
+	fmt.Println(Msg("targets_saved", filename, len(targets)))
+	return nil
+}
+
+func (r *parseRun) parse(host string, filename string, nohosts []string, withSource bool) (Result, error) {
+	var stats ipStreamStats
+	if withSource {
+		stats.sources = make(map[string]string)
+	}
+	stream, err := r.parseIPStream(host, filename, nohosts, &stats)
+	if err != nil {
+		return Result{}, err
+	}
+	var hosts []string
+	for ip := range stream {
+		hosts = append(hosts, ip)
+	}
+	if r.ctx.Err() != nil {
+		return Result{}, r.ctx.Err()
+	}
+	if stats.err != nil {
+		return Result{}, stats.err
+	}
+	if host == "" && filename != "" && stats.total == 0 && len(r.res.HostPort) == 0 {
+		// 只有文件输入:打不开返回包装后的打开错误(可用 errors.Is(err, os.ErrNotExist) 判断),解析不出目标返回 EmptyHostFileErr
+		return Result{}, fmt.Errorf("%w: %s", EmptyHostFileErr, filename)
+	}
+	if stats.total > 0 && len(hosts) == 0 && len(r.res.HostPort) == 0 {
+		return Result{}, fmt.Errorf("%w: %d hosts before exclusion, 0 after", ExcludeAllErr, stats.total)
+	}
+	hosts = r.dedupHosts(RemoveDuplicate(hosts))
+	if SamplePerSubnet > 0 {
+		hosts = r.sampleSubnets(hosts, SamplePerSubnet)
+	}
+	hosts = filterCDN(hosts, r.logf)
+	set := newHostSet()
+	for _, host := range hosts {
+		set.add(host)
+	}
+	r.res.HostPort = mergeHostPorts(set, RemoveDuplicate(r.res.HostPort), r.ports, r.res.Names)
+	if RotateM > 0 {
+		hosts = RotateHosts(hosts)
+		r.res.HostPort = RotateHosts(r.res.HostPort)
+	}
+	hosts = SortIPs(hosts)
+	if hosts, err = r.enforceAllowScope(hosts); err != nil {
+		return Result{}, err
+	}
+	if len(hosts) == 0 && len(r.res.HostPort) == 0 && (host != "" || filename != "") {
+		err = ParseIPErr
+	}
+	r.res.Hosts, r.res.Sources = hosts, stats.sources
+	return r.res, err
+}
+
+// 去掉已被 主机+-p端口 覆盖的 host:port,同一端点不从端口扫描和 HostPort 两条路径各探测一次
+func MergeHostPorts(hosts, hostPorts []string, ports string) []string {
+	if len(hosts) == 0 || len(hostPorts) == 0 {
+		return hostPorts
+	}
+	set := newHostSet()
+	for _, host := range hosts {
+		set.add(host)
+	}
+	return mergeHostPorts(set, hostPorts, ports, nil)
+}
+
+// host:port 中的域名同样按 canonicalHost 换成ip后判断
+func mergeHostPorts(hosts *hostSet, hostPorts []string, ports string, names map[string]string) []string {
+	if hosts.count == 0 || len(hostPorts) == 0 {
+		return hostPorts
+	}
+	portSet := make(map[string]struct{})
+	for _, port := range ParsePort(ports) {
+		portSet[strconv.Itoa(port)] = struct{}{}
+	}
+	var result []string
+	for _, target := range hostPorts {
+		index := strings.LastIndex(target, ":")
+		hostOK := hosts.has(canonicalHost(strings.Trim(target[:index], "[]"), names))
+		_, portOK := portSet[target[index+1:]]
+		if !hostOK || !portOK {
+			result = append(result, target)
+		}
+	}
+	return result
+}
+
+// 流式解析的计数,通道关闭后才可读
+type ipStreamStats struct {
+	total    int // 排除前的主机数(含重复)
+	excluded int
+	sources  map[string]string // 非nil时记录每个主机的来源
+	err      error             // 读文件中途出错,如超过 MaxHostsFromFile
+}
+
+// 逐个产出主机,不把整个列表放进内存,适合超大范围;排除和去重也在流中完成。
+// 去重是精确的,ipv4用位图,内存按出现过的/16计,每个8KB。
+// -resolve 合并、CDN过滤、-rotate、-allow-scope 逐个主机判断,与 ParseIP 的结果一致;
+// 子网抽样和排序需要完整列表,只在 ParseIP 中做,需要它们时用 NeedHostList 判断后改用 ParseIP。
+// host:ports 形式和文件中的 host:port 行在通道读完、关闭前并入 HostPort,
+// 因此调用方必须把通道读完后再使用 HostPort
+// 通道按 -rate 限速产出(在去重和过滤之后,被丢弃的主机不占速率),读取方无论开多少并发都不会超过该速率
+func ParseIPChan(host, filename string, nohosts ...string) (<-chan string, error) {
+	r := defaultRun(context.Background())
+	stream, err := r.parseIPStream(host, filename, nohosts, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan string, 1024)
+	go func() {
+		defer close(out)
+		filter := r.newStreamFilter()
+		for ip := range stream {
+			if ip, ok := filter.keep(ip); ok {
+				HostLimiter.Wait()
+				out <- ip
+			}
+		}
+		filter.finish()
+		publishResult(r.res)
+	}()
+	return out, nil
+}
+
+// 扫描阶段是否需要先拿到完整的主机列表:导出目标或范围、子网抽样、-allow-scope-abort(有越界目标时一个都不扫)
+func NeedHostList() bool {
+	return TargetsJson != "" || ExportScope != "" || SamplePerSubnet > 0 || AllowScopeAbort && len(AllowNets) > 0
+}
+
+// ParseIPChan 中逐个主机做的后处理,对应 parse 中的 dedupHosts、filterCDN、RotateHosts、enforceAllowScope
+type streamFilter struct {
+	r        *parseRun
+	seen     *hostSet // -resolve 合并后再去重一次
+	emitted  *hostSet // 实际产出的主机,结束时用于合并 host:port
+	cdn      map[string]int
+	cdnTotal int
+	dropped  []string
+	outScope int
+}
+
+func (r *parseRun) newStreamFilter() *streamFilter {
+	return &streamFilter{r: r, seen: newHostSet(), emitted: newHostSet(), cdn: make(map[string]int)}
+}
+
+func (f *streamFilter) keep(ip string) (string, bool) {
+	if canonical := canonicalHost(ip, f.r.res.Names); canonical != ip {
+		f.r.res.Aliases[canonical] = append(f.r.res.Aliases[canonical], ip)
+		if _, ok := f.r.res.Names[canonical]; !ok {
+			f.r.res.Names[canonical] = ip
+		}
+		if tag := f.r.tagOf(ip); tag != "" {
+			f.r.setTag(canonical, tag)
+		}
+		ip = canonical
+	}
+	if f.seen.add(ip) {
+		return "", false
+	}
+	if provider := CDNProvider(ip); provider != "" {
+		f.cdn[provider]++
+		f.cdnTotal++
+		if SkipCDN {
+			return "", false
+		}
+	}
+	if RotateM > 0 && !inRotation(ip) {
+		return "", false
+	}
+	if len(AllowNets) > 0 && !InAllowScope(ip) {
+		f.outScope++
+		if len(f.dropped) < maxScopeLog {
+			f.dropped = append(f.dropped, ip)
+		}
+		return "", false
+	}
+	f.emitted.add(ip)
+	// 扫描与解析同时进行,标签要在产出时就生效,不能等流结束后的 publishResult
+	if tag := f.r.tagOf(ip); tag != "" {
+		SetHostTag(ip, tag)
+	}
+	return ip, true
+}
+
+// 流结束后输出汇总,并对 HostPort 做与 parse 相同的去重、合并、分片和范围检查
+func (f *streamFilter) finish() {
+	r := f.r
+	logCDN(f.cdn, f.cdnTotal, r.logf)
+	r.res.HostPort = mergeHostPorts(f.emitted, RemoveDuplicate(r.res.HostPort), r.ports, r.res.Names)
+	if RotateM > 0 {
+		r.res.HostPort = RotateHosts(r.res.HostPort)
+	}
+	if len(AllowNets) > 0 {
+		var hostPorts []string
+		for _, target := range r.res.HostPort {
+			host := strings.Trim(target[:strings.LastIndex(target, ":")], "[]")
+			if InAllowScope(host) {
+				hostPorts = append(hostPorts, target)
+				continue
+			}
+			f.outScope++
+			if len(f.dropped) < maxScopeLog {
+				f.dropped = append(f.dropped, host)
+			}
+		}
+		r.res.HostPort = hostPorts
+	}
+	if f.outScope > 0 {
+		r.logOutOfScope(f.dropped, f.outScope)
+	}
+}
 
-				for _, host := range nohosts {
-					delete(temp, host)
+func (r *parseRun) parseIPStream(host, filename string, nohosts []string, stats *ipStreamStats) (<-chan string, error) {
+	streaming := stats == nil
+	if streaming {
+		stats = &ipStreamStats{}
+	}
+	out := make(chan string, 1024)
+	if hostPart, portPart, ok := splitHostPorts(host); filename == "" && ok {
+		//192.168.0.0/16:80,443 10.0.0.1-50:8000-8100 [2001:db8::/120]:80,展开为 host:port 组合
+		ports, err := parseTargetPorts(portPart)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port spec in %s: %w", host, err)
+		}
+		targets := r.parseIPs(hostPart)
+		if len(targets)*len(ports) > MaxHostPort {
+			return nil, fmt.Errorf("%s expands to %d host:port targets, more than the limit %d", host, len(targets)*len(ports), MaxHostPort)
+		}
+		for _, target := range targets {
+			for _, port := range ports {
+				r.res.HostPort = append(r.res.HostPort, net.JoinHostPort(target, strconv.Itoa(port)))
+			}
+		}
+		close(out)
+		return out, nil
+	}
+	var file io.ReadCloser
+	if filename != "" {
+		var err error
+		if file, err = OpenTargetFile(filename); err != nil {
+			return nil, fmt.Errorf("open %s error, %w", filename, err)
+		}
+	}
+	var rules []excludeRule
+	if len(nohosts) > 0 {
+		rules = parseExcludes(strings.Split(nohosts[0], ","))
+	}
+	seen := newHostSet()
+	origin := "cli"
+	var item string
+	emit := func(ip string) {
+		stats.total++
+		if excludedBy(rules, ip) {
+			stats.excluded++
+			return
+		}
+		if !seen.add(ip) {
+			if stats.sources != nil {
+				stats.sources[ip] = itemSource(origin, item, ip)
+			}
+			select {
+			case out <- ip:
+			case <-r.ctx.Done():
+			}
+		}
+	}
+	go func() {
+		defer close(out)
+		if host != "" {
+			for _, ip := range splitTargets(host) {
+				if isURLTarget(ip) {
+					if _, err := r.addURLTarget(ip); err != nil {
+						r.logf("[-] skip %s: %v", ip, err)
+					}
+					continue
 				}
+				item = ip
+				if tag := r.tags[strings.TrimSpace(ip)]; tag != "" {
+					r.streamIPProgress(ip, func(host string) {
+						r.setTag(host, tag)
+						emit(host)
+					})
+					continue
+				}
+				r.streamIPProgress(ip, emit)
+			}
+		}
+		if file != nil {
+			origin = "file:" + filename
+			stats.err = r.scanIPFile(file, func(ip, fileItem string) {
+				item = fileItem
+				emit(ip)
+			})
+			if stats.err != nil && streaming {
+				// ParseIPChan 没有返回错误的途径,只能输出后提前结束
+				r.log("[-]", stats.err)
+			}
+			file.Close()
+		}
+	}()
+	return out, nil
+}
+
+// 来源标签,item 为产生该主机的原始写法
+func itemSource(origin, item, ip string) string {
+	item = strings.TrimSpace(item)
+	if index := strings.Index(item, "!"); index != -1 {
+		item = item[:index]
+	}
+	switch {
+	case item == ip:
+		return origin
+	case isLocalTarget(item):
+		return origin + "," + item
+	case strings.Contains(item, "/"):
+		return origin + ",cidr:" + item
+	case strings.HasPrefix(item, "private:") || PrivateShorthand && privateShorthands[item] != "":
+		cidr, _ := privateRange(item)
+		return origin + ",cidr:" + cidr
+	case net.ParseIP(ip) != nil && hostnameReg.MatchString(item) && strings.IndexFunc(item, unicode.IsLetter) != -1:
+		return origin + ",resolve:" + item
+	case strings.Contains(item, "-"):
+		return origin + ",range:" + item
+	}
+	return origin
+}
+
+// 精确的主机集合,用于目标去重和扫描范围。不能用 -low-memory 的布隆过滤器(误判的主机会被漏扫);
+// ipv4按/16分块记录位图,每块8KB,完整展开一个/8也只占2MB,其余(ipv6、域名)用map。不加锁,并发使用时由调用方加锁
+type hostSet struct {
+	blocks map[uint32]*[1024]uint64
+	others map[string]struct{}
+	count  int
+}
+
+func newHostSet() *hostSet {
+	return &hostSet{blocks: make(map[uint32]*[1024]uint64), others: make(map[string]struct{})}
+}
+
+func hostSetKey(ip string) (uint32, bool) {
+	if strings.Contains(ip, ":") {
+		return 0, false
+	}
+	ip4 := net.ParseIP(ip).To4()
+	if ip4 == nil {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(ip4), true
+}
+
+// 加入集合,返回true表示已存在
+func (s *hostSet) add(ip string) bool {
+	if num, ok := hostSetKey(ip); ok {
+		block, ok := s.blocks[num>>16]
+		if !ok {
+			block = new([1024]uint64)
+			s.blocks[num>>16] = block
+		}
+		word, bit := (num&0xffff)>>6, uint64(1)<<(num&63)
+		if block[word]&bit != 0 {
+			return true
+		}
+		block[word] |= bit
+		s.count++
+		return false
+	}
+	if _, ok := s.others[ip]; ok {
+		return true
+	}
+	s.others[ip] = struct{}{}
+	s.count++
+	return false
+}
+
+func (s *hostSet) has(ip string) bool {
+	if num, ok := hostSetKey(ip); ok {
+		block, ok := s.blocks[num>>16]
+		return ok && block[(num&0xffff)>>6]&(uint64(1)<<(num&63)) != 0
+	}
+	_, ok := s.others[ip]
+	return ok
+}
+
+// ipv4 cidr按数值逐个产出,其余格式范围有限,沿用 parseIP 的结果
+// 展开的目标数不少于该值时才输出进度
+var ProgressMinHosts int64 = 1 << 16
+
+// 大范围展开时的进度,每过10%且距上次输出至少2秒才输出一次,ETA按已用时间和剩余数量估算
+type enumProgress struct {
+	logf        func(format string, a ...interface{})
+	name        string
+	total, done int64
+	start, last time.Time
+	nextPercent int64
+}
+
+func (p *enumProgress) step() {
+	p.done++
+	percent := p.done * 100 / p.total
+	if percent < p.nextPercent || time.Since(p.last) < 2*time.Second {
+		return
+	}
+	p.last = time.Now()
+	p.nextPercent = percent/10*10 + 10
+	elapsed := time.Since(p.start)
+	eta := time.Duration(float64(elapsed) / float64(p.done) * float64(p.total-p.done))
+	p.logf("[*] enumerating %s: %d%% (%d/%d), eta %v", p.name, percent, p.done, p.total, eta.Round(time.Second))
+}
+
+func (r *parseRun) streamIPProgress(ip string, emit func(string)) {
+	total := countIP(strings.TrimSpace(ip))
+	if total < ProgressMinHosts {
+		r.streamIP(ip, emit)
+		return
+	}
+	now := time.Now()
+	p := &enumProgress{logf: r.logf, name: ip, total: total, start: now, last: now, nextPercent: 10}
+	r.streamIP(ip, func(host string) {
+		if p.done < p.total {
+			p.step()
+		}
+		emit(host)
+	})
+}
 
-				var newDatas []string
-				for host := range temp {
-					newDatas = append(newDatas, host)
+// -ip8-full 时/8也按数值完整展开,不再抽样
+func (r *parseRun) streamIP(ip string, emit func(string)) {
+	if block, rules, ok := splitInlineExclude(ip); ok {
+		r.streamIP(block, func(host string) {
+			if !excludedBy(rules, host) {
+				emit(host)
+			}
+		})
+		return
+	}
+	if isBarePrivate(ip) {
+		r.log(Msg("private_shorthand_off", ip))
+		return
+	}
+	if cidr, ok := privateRange(ip); ok {
+		r.log(Msg("private_expand", ip, cidr))
+		ip = cidr
+	}
+	if isLocalTarget(ip) {
+		for _, cidr := range r.localTargets(ip) {
+			r.streamIP(cidr, emit)
+		}
+		return
+	}
+	_, _, sampled := sampledPrefix(ip)
+	if strings.Contains(ip, "/") && (FullScan || !strings.HasSuffix(ip, "/8")) && !sampled && strings.Count(ip, ":") < 2 {
+		_, ipNet, err := net.ParseCIDR(ip)
+		if err != nil {
+			return
+		}
+		if ip4 := ipNet.IP.To4(); ip4 != nil {
+			ones, _ := ipNet.Mask.Size()
+			start := uint64(binary.BigEndian.Uint32(ip4))
+			end := start | (1<<(32-ones) - 1)
+			if !r.checkIPRange(ip, end-start+1) {
+				return
+			}
+			// -skip-network-broadcast 按实际前缀去掉首尾,/23 的广播地址是 x.x.1.255;/31、/32 没有网络和广播地址
+			if SkipNetBroadcast && ones <= 30 {
+				start, end = start+1, end-1
+			}
+			current := make(net.IP, 4)
+			for num := start; num <= end; num++ {
+				if num&0xffff == 0 && r.ctx.Err() != nil {
+					return
 				}
-				hosts = newDatas
-				sort.Strings(hosts)
+				binary.BigEndian.PutUint32(current, uint32(num))
+				emit(current.String())
 			}
+			return
 		}
 	}
-	hosts = RemoveDuplicate(hosts)
-	if len(hosts) == 0 && len(HostPort) == 0 && host != "" && filename != "" {
-		err = ParseIPErr
+	for _, host := range r.parseIP(ip) {
+		if r.ctx.Err() != nil {
+			return
+		}
+		emit(host)
+	}
+}
+
+// 私网简写,需写成 private:10,或开启 -private-shorthand 后直接写 10
+var privateShorthands = map[string]string{
+	"192": "192.168.0.0/16",
+	"172": "172.16.0.0/12",
+	"10":  "10.0.0.0/8",
+}
+
+func privateRange(ip string) (string, bool) {
+	name := strings.TrimPrefix(ip, "private:")
+	cidr, ok := privateShorthands[name]
+	if !ok || name == ip && !PrivateShorthand {
+		return "", false
+	}
+	return cidr, true
+}
+
+// 未开启 -private-shorthand 时裸写的 192/172/10 视为无效,避免误写一个10就扫了整个/8
+func isBarePrivate(ip string) bool {
+	_, ok := privateShorthands[ip]
+	return ok && !PrivateShorthand
+}
+
+func isLocalTarget(ip string) bool {
+	return ip == "local" || strings.HasPrefix(ip, "local:")
+}
+
+// 本机网卡所在的网段,local 取全部已启用的非回环网卡,local:eth0 只取指定网卡;
+// 跳过链路本地地址和大于/112的ipv6网段
+func LocalCIDRs(target string) ([]string, error) {
+	name := strings.TrimPrefix(strings.TrimPrefix(target, "local"), ":")
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var cidrs []string
+	found := false
+	for _, iface := range ifaces {
+		if name != "" && iface.Name != name {
+			continue
+		}
+		found = true
+		if iface.Flags&net.FlagUp == 0 || name == "" && iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			if ones, bits := ipNet.Mask.Size(); bits == 128 && bits-ones > 16 {
+				continue
+			}
+			cidrs = append(cidrs, (&net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask}).String())
+		}
+	}
+	if name != "" && !found {
+		return nil, fmt.Errorf("no interface named %s", name)
+	}
+	if len(cidrs) == 0 {
+		return nil, fmt.Errorf("no usable address on %s", target)
+	}
+	return RemoveDuplicate(cidrs), nil
+}
+
+// 展开 local 目标前输出得到的网段
+func (r *parseRun) localTargets(ip string) []string {
+	cidrs, err := LocalCIDRs(ip)
+	if err != nil {
+		r.logf("[-] %s: %v", ip, err)
+		return nil
+	}
+	r.logf("[*] %s: %s", ip, strings.Join(cidrs, ","))
+	return cidrs
+}
+
+// url形式的目标:host:port -> url,web插件按该url访问,保留协议和路径
+var TargetURLs = make(map[string]string)
+
+func isURLTarget(item string) bool {
+	return strings.Contains(item, "://")
+}
+
+// 解析 http(s)://host[:port][/path],未写端口时 http 为80、https 为443,其他协议必须写端口
+func parseURLTarget(item string) (*url.URL, int, error) {
+	u, err := url.Parse(strings.TrimSpace(item))
+	if err != nil {
+		return nil, 0, err
+	}
+	if u.Hostname() == "" {
+		return nil, 0, errors.New("missing host")
+	}
+	if u.Port() == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "http":
+			return u, 80, nil
+		case "https":
+			return u, 443, nil
+		}
+		return nil, 0, fmt.Errorf("no default port for scheme %s", u.Scheme)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil || port < 1 || port > 65535 {
+		return nil, 0, fmt.Errorf("invalid port %s", u.Port())
+	}
+	return u, port, nil
+}
+
+// url中的主机照常展开(可以是ip段),逐个写入 HostPort 并记录url,返回展开的主机数;
+// 展开出多个主机时url中的主机换成对应的ip,只有一个时保留原样,以免丢掉域名
+func (r *parseRun) addURLTarget(item string) (int, error) {
+	u, port, err := parseURLTarget(item)
+	if err != nil {
+		return 0, err
+	}
+	hosts := r.parseIPs(u.Hostname())
+	for _, host := range hosts {
+		target := net.JoinHostPort(host, strconv.Itoa(port))
+		r.res.HostPort = append(r.res.HostPort, target)
+		hostURL := *u
+		if len(hosts) > 1 {
+			hostURL.Host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		r.res.URLs[target] = hostURL.String()
+	}
+	return len(hosts), nil
+}
+
+// 拆分 host:ports,ipv6需要写成 [::1]:8080 的形式;private:10 不是 host:port
+func splitHostPorts(target string) (string, string, bool) {
+	if _, ok := privateRange(target); ok || isURLTarget(target) || isLocalTarget(target) {
+		return "", "", false
 	}
+	if strings.HasPrefix(target, "[") {
+		index := strings.Index(target, "]:")
+		if index == -1 {
+			return "", "", false
+		}
+		return target[1:index], target[index+2:], true
+	}
+	if strings.Count(target, ":") == 1 {
+		index := strings.Index(target, ":")
+		return target[:index], target[index+1:], true
+	}
+	return "", "", false
+}
+
+// 排除规则,cidr和ip段按数值区间判断,不用先展开成字符串
+type excludeRule struct {
+	ipNet      *net.IPNet
+	start, end uint32
+	isRange    bool
+	exact      string
+}
+
+func parseExclude(entry string) (rule excludeRule) {
+	entry = strings.TrimSpace(entry)
+	rule.exact = entry
+	if strings.Contains(entry, "/") {
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			rule.ipNet = ipNet
+		}
+		return
+	}
+	index := strings.Index(entry, "-")
+	if index == -1 {
+		return
+	}
+	start := net.ParseIP(entry[:index]).To4()
+	if start == nil {
+		return
+	}
+	end := net.ParseIP(entry[index+1:]).To4()
+	if end == nil {
+		// 192.168.1.1-100
+		last, err := strconv.Atoi(entry[index+1:])
+		if err != nil || last < 0 || last > 255 {
+			return
+		}
+		end = net.IPv4(start[0], start[1], start[2], byte(last)).To4()
+	}
+	rule.start, rule.end, rule.isRange = binary.BigEndian.Uint32(start), binary.BigEndian.Uint32(end), true
 	return
 }
 
-func ParseIPs(ip string) (hosts []string) {
+func (rule excludeRule) match(host string) bool {
+	if host == rule.exact {
+		return true
+	}
+	if rule.ipNet == nil && !rule.isRange {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if rule.ipNet != nil {
+		return rule.ipNet.Contains(ip)
+	}
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return false
+	}
+	num := binary.BigEndian.Uint32(ip4)
+	return num >= rule.start && num <= rule.end
+}
+
+func parseExcludes(excludes []string) []excludeRule {
+	var rules []excludeRule
+	for _, entry := range excludes {
+		if strings.TrimSpace(entry) != "" {
+			rules = append(rules, parseExclude(entry))
+		}
+	}
+	return rules
+}
+
+func excludedBy(rules []excludeRule, host string) bool {
+	for _, rule := range rules {
+		if rule.match(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// 从hosts中去掉命中排除列表的主机,排除段与扫描范围部分重叠时只去掉重叠的部分,保持原有顺序
+func filterExcluded(hosts []string, excludes []string) []string {
+	rules := parseExcludes(excludes)
+	if len(rules) == 0 {
+		return hosts
+	}
+	var result []string
+	for _, host := range hosts {
+		if !excludedBy(rules, host) {
+			result = append(result, host)
+		}
+	}
+	return result
+}
+
+// 按逗号拆分目标,"段!排除项" 之后的逗号项如果落在该段内,视为同一个排除表达式的一部分:
+// 10.0.0.0/24!10.0.0.1,10.0.0.254,10.0.1.1 拆为 10.0.0.0/24!10.0.0.1,10.0.0.254 和 10.0.1.1
+func splitTargets(list string) []string {
+	var targets []string
+	for _, item := range strings.Split(list, ",") {
+		if n := len(targets); n > 0 {
+			if index := strings.Index(targets[n-1], "!"); index != -1 && withinBlock(targets[n-1][:index], item) {
+				targets[n-1] += "," + item
+				continue
+			}
+		}
+		targets = append(targets, item)
+	}
+	return targets
+}
+
+// item 的首尾地址都在 block 内
+func withinBlock(block, item string) bool {
+	rule := parseExclude(block)
+	if rule.ipNet == nil && !rule.isRange {
+		return false
+	}
+	first, last := strings.TrimSpace(item), strings.TrimSpace(item)
+	if itemRule := parseExclude(item); itemRule.ipNet != nil {
+		bounds := strings.SplitN(IPRange(itemRule.ipNet), "-", 2)
+		if len(bounds) != 2 {
+			return false
+		}
+		first, last = bounds[0], bounds[1]
+	} else if itemRule.isRange {
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, itemRule.start)
+		first = ip.String()
+		binary.BigEndian.PutUint32(ip, itemRule.end)
+		last = ip.String()
+	}
+	return rule.match(first) && rule.match(last)
+}
+
+// 拆出 段!排除项,排除项之间可以用逗号或!分隔
+func splitInlineExclude(ip string) (string, []excludeRule, bool) {
+	index := strings.Index(ip, "!")
+	if index == -1 {
+		return ip, nil, false
+	}
+	excludes := strings.FieldsFunc(ip[index+1:], func(r rune) bool { return r == ',' || r == '!' })
+	return strings.TrimSpace(ip[:index]), parseExcludes(excludes), true
+}
+
+func ParseIPs(ip string) []string {
+	r := defaultRun(context.Background())
+	hosts := r.parseIPs(ip)
+	publishResult(r.res)
+	return hosts
+}
+
+func (r *parseRun) parseIPs(ip string) (hosts []string) {
 	if strings.Contains(ip, ",") {
-		IPList := strings.Split(ip, ",")
+		IPList := splitTargets(ip)
 		var ips []string
 		for _, ip := range IPList {
-			ips = parseIP(ip)
+			ips = r.parseIP(ip)
 			hosts = append(hosts, ips...)
 		}
 	} else {
-		hosts = parseIP(ip)
+		hosts = r.parseIP(ip)
 	}
 	return hosts
 }
 
 func parseIP(ip string) []string {
+	return ParseIPs(ip)
+}
+
+// ctx取消时大范围展开提前返回nil
+func (r *parseRun) parseIP(ip string) []string {
 	reg := regexp.MustCompile(`[a-zA-Z]+`)
+	block, rules, inline := splitInlineExclude(ip)
+	sampleBase, sampleLen, sampled := sampledPrefix(ip)
+	privateCIDR, privateOK := privateRange(ip)
 	switch {
-	case ip == "192":
-		return parseIP("192.168.0.0/8")
-	case ip == "172":
-		return parseIP("172.16.0.0/12")
-	case ip == "10":
-		return parseIP("10.0.0.0/8")
-	// 扫描/8时,只扫网关和随机IP,避免扫描过多IP
-	case strings.HasSuffix(ip, "/8"):
-		return parseIP8(ip)
+	//10.0.0.0/24!10.0.0.1,10.0.0.254 段内排除
+	case inline:
+		var hosts []string
+		for _, host := range r.parseIP(block) {
+			if !excludedBy(rules, host) {
+				hosts = append(hosts, host)
+			}
+		}
+		return hosts
+	case isBarePrivate(ip):
+		r.log(Msg("private_shorthand_off", ip))
+		return nil
+	case privateOK:
+		r.log(Msg("private_expand", ip, privateCIDR))
+		return r.parseIP(privateCIDR)
+	//local、local:eth0 本机网卡所在网段
+	case isLocalTarget(ip):
+		var hosts []string
+		for _, cidr := range r.localTargets(ip) {
+			hosts = append(hosts, r.parseIP(cidr)...)
+		}
+		return hosts
+	//3232235777、0xC0A80101 等整数形式的ipv4
+	case isIntIP(ip):
+		return []string{intToIP(ip)}
+	//ipv6,保留 %zone
+	case strings.Count(ip, ":") >= 2:
+		return r.parseIPv6(ip)
+	// 扫描/8时,只扫网关和随机IP,避免扫描过多IP,-ip8-full 时完整展开
+	case strings.HasSuffix(ip, "/8") && !FullScan:
+		return parseIP8(r.ctx, ip)
+	// -sample 时 /9-/23 同样抽样
+	case sampled:
+		return sampleSubnetCtx(r.ctx, sampleBase, sampleLen)
 	//解析 /24 /16 /8 /xxx 等
 	case strings.Contains(ip, "/"):
-		return parseIP2(ip)
-	//可能是域名,用lookup获取ip
+		return r.parseIP2(ip)
+	//可能是域名,-dns-resolve 时解析为ip
+	case reg.MatchString(ip) && DnsResolve:
+		return r.resolveHost(ip)
 	case reg.MatchString(ip):
 		//	_, err := net.LookupHost(ip)
 		//	if err != nil {
@@ -109,7 +1075,7 @@ func parseIP(ip string) []string {
 		return []string{ip}
 	//192.168.1.1-192.168.1.100
 	case strings.Contains(ip, "-"):
-		return parseIP1(ip)
+		return r.parseIP1(ip)
 	//处理单个ip
 	default:
 		testIP := net.ParseIP(ip)
@@ -120,13 +1086,97 @@ func parseIP(ip string) []string {
 	}
 }
 
+// 全是数字且不超过 4294967295,或 0x 开头的不超过8位十六进制数,才当作整数ip,避免误判主机名
+func isIntIP(ip string) bool {
+	if strings.HasPrefix(ip, "0x") || strings.HasPrefix(ip, "0X") {
+		_, err := strconv.ParseUint(ip[2:], 16, 32)
+		return len(ip) > 2 && err == nil
+	}
+	if ip == "" || strings.Trim(ip, "0123456789") != "" {
+		return false
+	}
+	_, err := strconv.ParseUint(ip, 10, 32)
+	return err == nil
+}
+
+func intToIP(ip string) string {
+	base := 10
+	if strings.HasPrefix(ip, "0x") || strings.HasPrefix(ip, "0X") {
+		ip, base = ip[2:], 16
+	}
+	num, err := strconv.ParseUint(ip, base, 32)
+	if err != nil {
+		return ip
+	}
+	result := make(net.IP, 4)
+	binary.BigEndian.PutUint32(result, uint32(num))
+	return result.String()
+}
+
+// 解析ipv6地址和网段,如 fe80::1%eth0、fe80::/120%eth0,
+// 链路本地地址的zone会带到每个展开的地址上,网段最多展开到/112
+func (r *parseRun) parseIPv6(ip string) []string {
+	var zone string
+	if index := strings.Index(ip, "%"); index != -1 {
+		zone = ip[index:]
+		ip = ip[:index]
+		if end := strings.Index(zone, "/"); end != -1 {
+			ip += zone[end:]
+			zone = zone[:end]
+		}
+	}
+	if !strings.Contains(ip, "/") {
+		if net.ParseIP(ip) == nil {
+			return nil
+		}
+		return []string{ip + zone}
+	}
+	_, ipNet, err := net.ParseCIDR(ip)
+	if err != nil {
+		return nil
+	}
+	ones, bits := ipNet.Mask.Size()
+	if bits != 128 || bits-ones > 16 {
+		r.log(Msg("ipv6_too_large", ip))
+		return nil
+	}
+	var hosts []string
+	current := make(net.IP, len(ipNet.IP))
+	copy(current, ipNet.IP)
+	for ipNet.Contains(current) {
+		hosts = append(hosts, current.String()+zone)
+		i := len(current) - 1
+		for ; i >= 0; i-- {
+			current[i]++
+			if current[i] != 0 {
+				break
+			}
+		}
+		if i < 0 {
+			break
+		}
+	}
+	return hosts
+}
+
 // 把 192.168.x.x/xx 转换成 192.168.x.x-192.168.x.x
-func parseIP2(host string) (hosts []string) {
+func (r *parseRun) parseIP2(host string) (hosts []string) {
 	_, ipNet, err := net.ParseCIDR(host)
 	if err != nil {
 		return
 	}
-	hosts = parseIP1(IPRange(ipNet))
+	// /32 直接返回该ip;默认 /31、/30 不区分网络和广播地址,全部返回;/0-/7 超过 MaxIPRange 会被拒绝
+	ones, bits := ipNet.Mask.Size()
+	if bits == 32 && ones == 32 {
+		return []string{ipNet.IP.String()}
+	}
+	if bits == 32 && !r.checkIPRange(host, 1<<(32-ones)) {
+		return
+	}
+	hosts = r.parseIP1(IPRange(ipNet))
+	if bits == 32 && SkipNetBroadcast && ones <= 30 && len(hosts) > 2 {
+		hosts = hosts[1 : len(hosts)-1]
+	}
 	return
 }
 
@@ -134,59 +1184,73 @@ func parseIP2(host string) (hosts []string) {
 //
 //	192.168.111.1-255
 //	192.168.111.1-192.168.112.255
-func parseIP1(ip string) []string {
-	IPRange := strings.Split(ip, "-")
-	testIP := net.ParseIP(IPRange[0])
+func (r *parseRun) parseIP1(ip string) []string {
+	startNum, endNum, err := ipRangeBounds(ip)
+	if err != nil {
+		r.log(Msg("invalid_ip_range", ip, err))
+		return nil
+	}
+	if !r.checkIPRange(ip, uint64(endNum-startNum)+1) {
+		return nil
+	}
 	var AllIP []string
-	if len(IPRange[1]) < 4 {
-		Range, err := strconv.Atoi(IPRange[1])
-		if testIP == nil || Range > 255 || err != nil {
+	current := make(net.IP, 4)
+	for num := uint64(startNum); num <= uint64(endNum); num++ {
+		if num&0xffff == 0 && r.ctx.Err() != nil {
 			return nil
 		}
-		SplitIP := strings.Split(IPRange[0], ".")
-		ip1, err1 := strconv.Atoi(SplitIP[3])
-		ip2, err2 := strconv.Atoi(IPRange[1])
-		PrefixIP := strings.Join(SplitIP[0:3], ".")
-		if ip1 > ip2 || err1 != nil || err2 != nil {
-			return nil
-		}
-		for i := ip1; i <= ip2; i++ {
-			AllIP = append(AllIP, PrefixIP+"."+strconv.Itoa(i))
-		}
-	} else {
-		SplitIP1 := strings.Split(IPRange[0], ".")
-		SplitIP2 := strings.Split(IPRange[1], ".")
-		if len(SplitIP1) != 4 || len(SplitIP2) != 4 {
-			return nil
-		}
-		start, end := [4]int{}, [4]int{}
-		for i := 0; i < 4; i++ {
-			ip1, err1 := strconv.Atoi(SplitIP1[i])
-			ip2, err2 := strconv.Atoi(SplitIP2[i])
-			if ip1 > ip2 || err1 != nil || err2 != nil {
-				return nil
-			}
-			start[i], end[i] = ip1, ip2
-		}
-		startNum := start[0]<<24 | start[1]<<16 | start[2]<<8 | start[3]
-		endNum := end[0]<<24 | end[1]<<16 | end[2]<<8 | end[3]
-		for num := startNum; num <= endNum; num++ {
-			ip := strconv.Itoa((num>>24)&0xff) + "." + strconv.Itoa((num>>16)&0xff) + "." + strconv.Itoa((num>>8)&0xff) + "." + strconv.Itoa((num)&0xff)
-			AllIP = append(AllIP, ip)
-		}
+		binary.BigEndian.PutUint32(current, uint32(num))
+		AllIP = append(AllIP, current.String())
 	}
 	return AllIP
 }
 
+// 解析ip段的起止地址,短格式 192.168.1.1-100 只改最后一段;
+// 完整格式按32位整数比较,192.168.1.200-192.168.2.5 这种跨段范围是合法的
+func ipRangeBounds(ip string) (uint32, uint32, error) {
+	parts := strings.Split(ip, "-")
+	// 必须正好是 起始-结束 两段,1.1.1.1- 、-1.1.1.1 、1.1.1.1-2-3 都是错误输入
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return 0, 0, errors.New("need exactly one start-end pair")
+	}
+	start := net.ParseIP(parts[0]).To4()
+	if start == nil || strings.Count(parts[0], ".") != 3 {
+		return 0, 0, fmt.Errorf("invalid start ip %q", parts[0])
+	}
+	var end net.IP
+	if len(parts[1]) < 4 {
+		last, err := strconv.Atoi(parts[1])
+		if err != nil || last < 0 || last > 255 {
+			return 0, 0, fmt.Errorf("invalid last octet %q", parts[1])
+		}
+		end = net.IPv4(start[0], start[1], start[2], byte(last)).To4()
+	} else if end = net.ParseIP(parts[1]).To4(); end == nil || strings.Count(parts[1], ".") != 3 {
+		return 0, 0, fmt.Errorf("invalid end ip %q", parts[1])
+	}
+	startNum, endNum := binary.BigEndian.Uint32(start), binary.BigEndian.Uint32(end)
+	if startNum > endNum {
+		return 0, 0, errors.New("start is greater than end")
+	}
+	return startNum, endNum, nil
+}
+
 // 获取起始IP、结束IP
+// ip统一成4或16字节,ipv4搭配16字节掩码时取掩码后4字节;两者长度仍不一致时返回空
 func IPRange(c *net.IPNet) string {
-	start := c.IP.String()
-	mask := c.Mask
-	bcst := make(net.IP, len(c.IP))
-	copy(bcst, c.IP)
-	for i := 0; i < len(mask); i++ {
-		ipIdx := len(bcst) - i - 1
-		bcst[ipIdx] = c.IP[ipIdx] | ^mask[len(mask)-i-1]
+	ip, mask := c.IP, c.Mask
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		if ones, bits := mask.Size(); bits == 8*net.IPv6len && ones >= 96 {
+			mask = mask[net.IPv6len-net.IPv4len:]
+		}
+	}
+	if len(mask) != len(ip) {
+		return ""
+	}
+	start := ip.String()
+	bcst := make(net.IP, len(ip))
+	for i := range ip {
+		bcst[i] = ip[i] | ^mask[i]
 	}
 	end := bcst.String()
 	return fmt.Sprintf("%s-%s", start, end) //返回用-表示的ip段,192.168.1.0-192.168.255.255
@@ -194,36 +1258,134 @@ func IPRange(c *net.IPNet) string {
 
 // 按行读ip
 func Readipfile(filename string) ([]string, error) {
-	file, err := os.Open(filename)
+	file, err := OpenTargetFile(filename)
 	if err != nil {
-		fmt.Printf("Open %s error, %v", filename, err)
-		os.Exit(0)
+		return nil, fmt.Errorf("open %s error, %w", filename, err)
 	}
 	defer file.Close()
 	var content []string
+	r := defaultRun(context.Background())
+	err = r.scanIPFile(file, func(host, _ string) {
+		content = append(content, host)
+	})
+	publishResult(r.res)
+	return content, err
+}
+
+// 以 # 或 // 开头的整行注释返回空,行尾的 "# 说明" 去掉
+func stripComment(line string) string {
+	if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+		return ""
+	}
+	if index := strings.Index(line, "#"); index != -1 {
+		line = line[:index]
+	}
+	return strings.TrimSpace(line)
+}
+
+var commaSpaceReg = regexp.MustCompile(`\s*,\s*`)
+
+// 拆分文件中的一行目标:逗号两侧的空白去掉,第一个空白(空格、tab)之后的说明文字忽略,
+// host:ports 拆出端口部分,[ipv6] 和 [ipv6]:ports 去掉括号
+func splitTargetLine(line string) (host string, ports string, hasPort bool) {
+	fields := strings.Fields(commaSpaceReg.ReplaceAllString(strings.TrimSpace(line), ","))
+	if len(fields) == 0 {
+		return "", "", false
+	}
+	target := fields[0]
+	if hostPart, portPart, ok := splitHostPorts(target); ok {
+		return strings.TrimSpace(hostPart), strings.TrimSpace(portPart), true
+	}
+	if strings.HasPrefix(target, "[") && strings.HasSuffix(target, "]") {
+		target = target[1 : len(target)-1]
+	}
+	return target, "", false
+}
+
+// host:ports 中的端口部分,-h 和 -hf 共用,写法与 -p 一致,支持 80,443 和 8000-8100
+func parseTargetPorts(ports string) ([]int, error) {
+	ports = strings.TrimSpace(ports)
+	if ports == "" {
+		return nil, errors.New("empty port")
+	}
+	return ParsePorts(ports)
+}
+
+// 逐行解析目标文件,host:port 行写入 HostPort,其余主机连同产生它的原始写法交给emit;
+// 累计主机数超过 MaxHostsFromFile 时停止解析,返回的错误中带有超限的行号
+func (r *parseRun) scanIPFile(file io.Reader, emit func(host, item string)) error {
+	// 超限时取消,让正在展开的大网段尽快停下
+	parent := r.ctx
+	ctx, cancel := context.WithCancel(parent)
+	r.ctx = ctx
+	defer func() {
+		cancel()
+		r.ctx = parent
+	}()
+	var total int
+	var limitErr error
+	overLimit := func(lineNum int, line string, count int) bool {
+		total += count
+		if total > MaxHostsFromFile && limitErr == nil {
+			limitErr = fmt.Errorf("%w: line %d %q brings the total to %d, more than the limit %d (MaxHostsFromFile)", MaxHostsFileErr, lineNum, line, total, MaxHostsFromFile)
+			cancel()
+		}
+		return limitErr != nil
+	}
 	scanner := bufio.NewScanner(file)
 	scanner.Split(bufio.ScanLines)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	for lineNum := 1; scanner.Scan() && r.ctx.Err() == nil; lineNum++ {
+		line, tag := splitTag(strings.TrimSpace(scanner.Text()))
+		line, tag = stripComment(line), stripComment(tag)
 		if line != "" {
-			text := strings.Split(line, ":")
-			if len(text) == 2 {
-				port := strings.Split(text[1], " ")[0]
-				num, err := strconv.Atoi(port)
-				if err != nil || (num < 1 || num > 65535) {
+			hostPart, portPart, hasPort := splitTargetLine(line)
+			if hasPort {
+				ports, err := parseTargetPorts(portPart)
+				if err != nil {
+					r.logf("[-] skip %s: %v", line, err)
 					continue
 				}
-				hosts := ParseIPs(text[0])
+				hosts := r.parseIPs(hostPart)
+				if len(hosts)*len(ports) > MaxHostPort {
+					r.logf("[-] skip %s: expands to %d host:port targets, more than the limit %d", line, len(hosts)*len(ports), MaxHostPort)
+					continue
+				}
+				if overLimit(lineNum, line, len(hosts)*len(ports)) {
+					break
+				}
 				for _, host := range hosts {
-					HostPort = append(HostPort, fmt.Sprintf("%s:%s", host, port))
+					for _, port := range ports {
+						r.res.HostPort = append(r.res.HostPort, net.JoinHostPort(host, strconv.Itoa(port)))
+					}
+					if tag != "" {
+						r.setTag(host, tag)
+					}
 				}
 			} else {
-				host := ParseIPs(line)
-				content = append(content, host...)
+				for _, ip := range splitTargets(hostPart) {
+					if isURLTarget(ip) {
+						count, err := r.addURLTarget(ip)
+						if err != nil {
+							r.logf("[-] skip %s: %v", ip, err)
+						} else if overLimit(lineNum, line, count) {
+							break
+						}
+						continue
+					}
+					r.streamIPProgress(ip, func(host string) {
+						if overLimit(lineNum, line, 1) {
+							return
+						}
+						if tag != "" {
+							r.setTag(host, tag)
+						}
+						emit(host, ip)
+					})
+				}
 			}
 		}
 	}
-	return content, nil
+	return limitErr
 }
 
 // 去重
@@ -239,36 +1401,331 @@ func RemoveDuplicate(old []string) []string {
 	return result
 }
 
-func parseIP8(ip string) []string {
-	realIP := ip[:len(ip)-2]
-	testIP := net.ParseIP(realIP)
+// 每个/24最多随机保留n个主机,保证覆盖面的同时减少扫描量
+func SampleSubnets(hosts []string, n int) []string {
+	return defaultRun(context.Background()).sampleSubnets(hosts, n)
+}
+
+func (r *parseRun) sampleSubnets(hosts []string, n int) []string {
+	var subnets []string
+	groups := make(map[string][]string)
+	var result []string
+	for _, host := range hosts {
+		index := strings.LastIndex(host, ".")
+		if net.ParseIP(host).To4() == nil || index == -1 {
+			result = append(result, host)
+			continue
+		}
+		subnet := host[:index]
+		if _, ok := groups[subnet]; !ok {
+			subnets = append(subnets, subnet)
+		}
+		groups[subnet] = append(groups[subnet], host)
+	}
+	var dropped int
+	for _, subnet := range subnets {
+		group := groups[subnet]
+		if len(group) <= n {
+			result = append(result, group...)
+			continue
+		}
+		keep := RandPerm(len(group))[:n]
+		sort.Ints(keep)
+		for _, i := range keep {
+			result = append(result, group[i])
+		}
+		dropped += len(group) - n
+		if len(subnets) <= 256 {
+			r.log(Msg("sample_subnet", subnet, n, len(group)-n))
+		}
+	}
+	r.log(Msg("sample_summary", n, len(subnets), dropped))
+	return result
+}
+
+// 按ip数值排序(10.0.0.2 在 10.0.0.10 之前),ipv4在ipv6之前,无法解析为ip的主机名排在最后按字符串排序
+func SortIPs(hosts []string) []string {
+	type sortKey struct {
+		ip   []byte
+		host string
+	}
+	keys := make([]sortKey, len(hosts))
+	for i, host := range hosts {
+		keys[i].host = host
+		if ip := net.ParseIP(strings.Split(host, "%")[0]); ip != nil {
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			keys[i].ip = ip
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		switch {
+		case a.ip != nil && b.ip != nil:
+			if len(a.ip) != len(b.ip) {
+				return len(a.ip) < len(b.ip)
+			}
+			if c := bytes.Compare(a.ip, b.ip); c != 0 {
+				return c < 0
+			}
+			return a.host < b.host
+		case a.ip != nil || b.ip != nil:
+			return a.ip != nil
+		default:
+			return a.host < b.host
+		}
+	})
+	for i := range keys {
+		hosts[i] = keys[i].host
+	}
+	return hosts
+}
+
+// -rotate N-of-M 按主机哈希把目标稳定地分成M份,本次只扫第N份,M次运行覆盖全部
+func RotateHosts(targets []string) []string {
+	var result []string
+	for _, target := range targets {
+		if inRotation(target) {
+			result = append(result, target)
+		}
+	}
+	return result
+}
+
+// 主机或 host:port 是否属于本次的第N份
+func inRotation(target string) bool {
+	host := target
+	if strings.Count(target, ":") == 1 {
+		host = target[:strings.Index(target, ":")]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(host))
+	return int(h.Sum32()%uint32(RotateM)) == RotateN-1
+}
+
+// parseIP8 的采样方案:每个/24固定扫描的末位,以及每个区间随机抽取的个数
+type SampleBand struct {
+	Min, Max, Count int
+}
+
+type SampleProfile struct {
+	Fixed []int
+	Bands []SampleBand
+}
+
+// 默认扫网关常用的 .1 .2 .4 .5 .254,再从5个区间各随机抽1个
+var IP8Profile = SampleProfile{
+	Fixed: []int{1, 2, 4, 5, 254},
+	Bands: []SampleBand{{6, 55, 1}, {56, 100, 1}, {101, 150, 1}, {151, 200, 1}, {201, 253, 1}},
+}
+
+// 解析 -ip8-fixed 1,2,254 和 -ip8-bands 6-55:1,200-253:3;-ip8-bands 只写一个数字N时表示在1-254里随机挑N个,0为不随机。
+// 每个/8共65536个/24,每多探测一个末位就多65536个目标,覆盖率和耗时需要自己权衡
+func ParseSampleProfile(fixed, bands string) (profile SampleProfile, err error) {
+	profile = IP8Profile
+	if fixed != "" {
+		profile.Fixed = nil
+		for _, item := range strings.Split(fixed, ",") {
+			num, err := strconv.Atoi(strings.TrimSpace(item))
+			if err != nil || num < 0 || num > 255 {
+				return profile, fmt.Errorf("invalid fixed octet %q", item)
+			}
+			profile.Fixed = append(profile.Fixed, num)
+		}
+	}
+	if count, err := strconv.Atoi(strings.TrimSpace(bands)); err == nil {
+		if count < 0 || count > 254 {
+			return profile, fmt.Errorf("random count %d out of 0-254", count)
+		}
+		profile.Bands = nil
+		if count > 0 {
+			profile.Bands = []SampleBand{{Min: 1, Max: 254, Count: count}}
+		}
+	} else if bands != "" {
+		profile.Bands = nil
+		for _, item := range strings.Split(bands, ",") {
+			var band SampleBand
+			if _, err := fmt.Sscanf(strings.TrimSpace(item), "%d-%d:%d", &band.Min, &band.Max, &band.Count); err != nil {
+				return profile, fmt.Errorf("invalid band %q, as: 6-55:1", item)
+			}
+			if band.Min < 0 || band.Max > 255 || band.Min > band.Max || band.Count < 1 || band.Count > band.Max-band.Min+1 {
+				return profile, fmt.Errorf("invalid band %q", item)
+			}
+			profile.Bands = append(profile.Bands, band)
+		}
+	}
+	return profile, nil
+}
 
-	if testIP == nil {
+func parseIP8(ctx context.Context, ip string) []string {
+	_, ipNet, err := net.ParseCIDR(ip)
+	if err != nil || ipNet.IP.To4() == nil {
 		return nil
 	}
+	return sampleSubnetCtx(ctx, ipNet.IP, 8)
+}
 
-	IPrange := strings.Split(ip, ".")[0]
+// 按 IP8Profile 对网段内每个/24抽样:固定末位加各区间随机末位,prefixLen 需在8到24之间
+func sampleSubnet(base net.IP, prefixLen int) []string {
+	return sampleSubnetCtx(context.Background(), base, prefixLen)
+}
+
+func sampleSubnetCtx(ctx context.Context, base net.IP, prefixLen int) []string {
+	ip4 := base.To4()
+	if ip4 == nil || prefixLen < 8 || prefixLen > 24 {
+		return nil
+	}
+	start := binary.BigEndian.Uint32(ip4) &^ (1<<(32-prefixLen) - 1)
+	subnets := uint32(1) << (24 - prefixLen)
 	var AllIP []string
-	for a := 0; a <= 255; a++ {
-		for b := 0; b <= 255; b++ {
-			AllIP = append(AllIP, fmt.Sprintf("%s.%d.%d.%d", IPrange, a, b, 1))
-			AllIP = append(AllIP, fmt.Sprintf("%s.%d.%d.%d", IPrange, a, b, 2))
-			AllIP = append(AllIP, fmt.Sprintf("%s.%d.%d.%d", IPrange, a, b, 4))
-			AllIP = append(AllIP, fmt.Sprintf("%s.%d.%d.%d", IPrange, a, b, 5))
-			AllIP = append(AllIP, fmt.Sprintf("%s.%d.%d.%d", IPrange, a, b, RandInt(6, 55)))
-			AllIP = append(AllIP, fmt.Sprintf("%s.%d.%d.%d", IPrange, a, b, RandInt(56, 100)))
-			AllIP = append(AllIP, fmt.Sprintf("%s.%d.%d.%d", IPrange, a, b, RandInt(101, 150)))
-			AllIP = append(AllIP, fmt.Sprintf("%s.%d.%d.%d", IPrange, a, b, RandInt(151, 200)))
-			AllIP = append(AllIP, fmt.Sprintf("%s.%d.%d.%d", IPrange, a, b, RandInt(201, 253)))
-			AllIP = append(AllIP, fmt.Sprintf("%s.%d.%d.%d", IPrange, a, b, 254))
+	for n := uint32(0); n < subnets; n++ {
+		if n&0xff == 0 && ctx.Err() != nil {
+			return nil
+		}
+		num := start + n<<8
+		prefix := fmt.Sprintf("%d.%d.%d", byte(num>>24), byte(num>>16), byte(num>>8))
+		for _, last := range IP8Profile.Fixed {
+			AllIP = append(AllIP, fmt.Sprintf("%s.%d", prefix, last))
+		}
+		for _, band := range IP8Profile.Bands {
+			if band.Count == 1 {
+				AllIP = append(AllIP, fmt.Sprintf("%s.%d", prefix, RandInt(band.Min, band.Max)))
+				continue
+			}
+			for _, i := range RandPerm(band.Max - band.Min + 1)[:band.Count] {
+				AllIP = append(AllIP, fmt.Sprintf("%s.%d", prefix, band.Min+i))
+			}
 		}
 	}
 	return AllIP
 }
 
+// -sample 时大于/24的ipv4网段(如/16、/12)也按 parseIP8 的方案抽样,默认完整展开
+func sampledPrefix(ip string) (net.IP, int, bool) {
+	if !SampleLarge || strings.Count(ip, ":") >= 2 {
+		return nil, 0, false
+	}
+	_, ipNet, err := net.ParseCIDR(ip)
+	if err != nil || ipNet.IP.To4() == nil {
+		return nil, 0, false
+	}
+	ones, _ := ipNet.Mask.Size()
+	if ones < 9 || ones >= 24 {
+		return nil, 0, false
+	}
+	return ipNet.IP, ones, true
+}
+
 func RandInt(min, max int) int {
 	if min >= max || min == 0 || max == 0 {
 		return max
 	}
-	return rand.Intn(max-min) + min
+	return RandIntn(max-min) + min
+}
+
+// 不展开地估算目标数:cidr按前缀长度,ip段按首尾差值,/8按采样方案每个/24的个数计算。
+// 多个输入重叠时不去重,结果是上限;-hn 排除、-dns-resolve 多记录等也不计入
+func CountIPs(host, filename string) (int64, error) {
+	var total int64
+	if hostPart, _, ok := splitHostPorts(host); ok && filename == "" {
+		host = hostPart
+	}
+	if host != "" {
+		for _, ip := range splitTargets(host) {
+			total += countIP(strings.TrimSpace(ip))
+		}
+	}
+	if filename != "" {
+		file, err := OpenTargetFile(filename)
+		if err != nil {
+			return 0, fmt.Errorf("open %s error, %w", filename, err)
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line, _ := splitTag(strings.TrimSpace(scanner.Text()))
+			line = stripComment(line)
+			if line == "" {
+				continue
+			}
+			hostPart, _, _ := splitTargetLine(line)
+			for _, ip := range splitTargets(hostPart) {
+				total += countIP(strings.TrimSpace(ip))
+			}
+		}
+	}
+	return total, nil
+}
+
+func countIP(ip string) int64 {
+	if isURLTarget(ip) {
+		u, _, err := parseURLTarget(ip)
+		if err != nil {
+			return 0
+		}
+		ip = u.Hostname()
+	}
+	if index := strings.Index(ip, "!"); index != -1 {
+		ip = ip[:index]
+	}
+	if ip == "" || isBarePrivate(ip) {
+		return 0
+	}
+	if cidr, ok := privateRange(ip); ok {
+		ip = cidr
+	}
+	if isLocalTarget(ip) {
+		cidrs, _ := LocalCIDRs(ip)
+		var total int64
+		for _, cidr := range cidrs {
+			total += countIP(cidr)
+		}
+		return total
+	}
+	if strings.Count(ip, ":") >= 2 {
+		if !strings.Contains(ip, "/") {
+			return 1
+		}
+		_, ipNet, err := net.ParseCIDR(strings.Split(ip, "%")[0])
+		if err != nil {
+			return 0
+		}
+		ones, bits := ipNet.Mask.Size()
+		if bits-ones > 16 {
+			return 0
+		}
+		return 1 << (bits - ones)
+	}
+	if _, ones, sampled := sampledPrefix(ip); sampled || strings.HasSuffix(ip, "/8") && !FullScan {
+		if !sampled {
+			ones = 8
+		}
+		perSubnet := len(IP8Profile.Fixed)
+		for _, band := range IP8Profile.Bands {
+			perSubnet += band.Count
+		}
+		return int64(1) << (24 - ones) * int64(perSubnet)
+	}
+	if strings.Contains(ip, "/") {
+		_, ipNet, err := net.ParseCIDR(ip)
+		if err != nil {
+			return 0
+		}
+		ones, bits := ipNet.Mask.Size()
+		return 1 << (bits - ones)
+	}
+	index := strings.Index(ip, "-")
+	if index == -1 {
+		return 1
+	}
+	// 带 - 的域名
+	if net.ParseIP(ip[:index]).To4() == nil {
+		return 1
+	}
+	startNum, endNum, err := ipRangeBounds(ip)
+	if err != nil {
+		return 0
+	}
+	return int64(endNum-startNum) + 1
 }
@@ -0,0 +1,794 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIPsZone(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"fe80::1%eth0", []string{"fe80::1%eth0"}},
+		{"fe80::/126%eth0", []string{"fe80::%eth0", "fe80::1%eth0", "fe80::2%eth0", "fe80::3%eth0"}},
+		{"fe80::%eth0/127", []string{"fe80::%eth0", "fe80::1%eth0"}},
+		{"2001:db8::1", []string{"2001:db8::1"}},
+		{"fe80::1%eth0,fe80::2%eth1", []string{"fe80::1%eth0", "fe80::2%eth1"}},
+	}
+	for _, tt := range tests {
+		if got := ParseIPs(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseIPs(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestJoinAddrZone(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"fe80::1%eth0:22", "[fe80::1%eth0]:22"},
+		{"2001:db8::1:443", "[2001:db8::1]:443"},
+		{"[fe80::1%eth0]:22", "[fe80::1%eth0]:22"},
+		{"10.0.0.1:80", "10.0.0.1:80"},
+	}
+	for _, tt := range tests {
+		if got := JoinAddr(tt.in); got != tt.want {
+			t.Errorf("JoinAddr(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func writeHostFile(t *testing.T, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "hosts.txt")
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestParseIPHostFile(t *testing.T) {
+	hostsFile := writeHostFile(t, "10.0.0.1\n10.0.0.2\n")
+	emptyFile := writeHostFile(t, "")
+	commentFile := writeHostFile(t, "# only comments\n\n")
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+	tests := []struct {
+		name     string
+		host     string
+		filename string
+		want     []string
+		err      error
+		notExist bool
+	}{
+		{"file only", "", hostsFile, []string{"10.0.0.1", "10.0.0.2"}, nil, false},
+		{"host and file", "10.0.0.3", hostsFile, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, nil, false},
+		{"empty file", "", emptyFile, nil, EmptyHostFileErr, false},
+		{"comment only file", "", commentFile, nil, EmptyHostFileErr, false},
+		{"missing file", "", missing, nil, nil, true},
+		{"host and empty file", "10.0.0.3", emptyFile, []string{"10.0.0.3"}, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseIP(tt.host, tt.filename)
+		switch {
+		case tt.notExist:
+			if !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("%s: err = %v, want not exist", tt.name, err)
+			}
+		case tt.err != nil:
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("%s: unexpected err %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseIP8Seed(t *testing.T) {
+	oldSeed, oldRand := RandSeed, Rand
+	defer func() { RandSeed, Rand = oldSeed, oldRand }()
+
+	tests := []struct {
+		seed      int64
+		other     int64
+		ip        string
+		wantCount int
+	}{
+		{1, 2, "10.0.0.0/8", 65536 * (len(IP8Profile.Fixed) + bandCount())},
+		{42, 43, "172.0.0.0/8", 65536 * (len(IP8Profile.Fixed) + bandCount())},
+	}
+	run := func(seed int64, ip string) []string {
+		RandSeed = seed
+		InitRand()
+		return parseIP8(context.Background(), ip)
+	}
+	for _, tt := range tests {
+		first, second := run(tt.seed, tt.ip), run(tt.seed, tt.ip)
+		if len(first) != tt.wantCount {
+			t.Errorf("parseIP8(%q) got %d hosts, want %d", tt.ip, len(first), tt.wantCount)
+		}
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("parseIP8(%q) with seed %d differs between runs", tt.ip, tt.seed)
+		}
+		if reflect.DeepEqual(first, run(tt.other, tt.ip)) {
+			t.Errorf("parseIP8(%q) seeds %d and %d give the same list", tt.ip, tt.seed, tt.other)
+		}
+	}
+}
+
+func bandCount() (n int) {
+	for _, band := range IP8Profile.Bands {
+		n += band.Count
+	}
+	return n
+}
+
+// 不输出、不写全局变量的解析状态
+func newTestRun() *parseRun {
+	return (&Parser{}).newRun(context.Background())
+}
+
+func TestParseIP1CrossOctet(t *testing.T) {
+	tests := []struct {
+		in          string
+		count       int
+		first, last string
+	}{
+		{"10.0.0.250-10.0.1.5", 12, "10.0.0.250", "10.0.1.5"},
+		{"172.16.255.250-172.17.0.10", 17, "172.16.255.250", "172.17.0.10"},
+		{"192.168.1.200-192.168.2.5", 62, "192.168.1.200", "192.168.2.5"},
+		{"10.0.0.1-10.0.0.1", 1, "10.0.0.1", "10.0.0.1"},
+		{"10.0.1.5-10.0.0.250", 0, "", ""},
+	}
+	for _, tt := range tests {
+		got := newTestRun().parseIP1(tt.in)
+		if len(got) != tt.count {
+			t.Errorf("parseIP1(%q) got %d hosts, want %d", tt.in, len(got), tt.count)
+			continue
+		}
+		if tt.count > 0 && (got[0] != tt.first || got[len(got)-1] != tt.last) {
+			t.Errorf("parseIP1(%q) = %s..%s, want %s..%s", tt.in, got[0], got[len(got)-1], tt.first, tt.last)
+		}
+	}
+}
+
+// 逐行扫描目标,返回主机和 host:port
+func scanLines(t *testing.T, r io.Reader) ([]string, []string) {
+	t.Helper()
+	run := newTestRun()
+	var hosts []string
+	if err := run.scanIPFile(r, func(host, _ string) {
+		hosts = append(hosts, host)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return hosts, run.res.HostPort
+}
+
+func TestScanIPFileComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		hosts    []string
+		hostPort []string
+	}{
+		{"comment lines", "# dmz\n// office\n10.0.0.1\n", []string{"10.0.0.1"}, nil},
+		{"inline note", "10.0.0.1 # web server\n10.0.0.2# db\n", []string{"10.0.0.1", "10.0.0.2"}, nil},
+		{"host:port with note", "10.0.0.3:8080 # admin panel\n", nil, []string{"10.0.0.3:8080"}},
+		{"range with note", "10.0.0.0/30 # lab, in scope until june\n", []string{"10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3"}, nil},
+		{"indented comment", "   # spaced comment\n\t// tabbed\n", nil, nil},
+		{"mixed", "# header\n10.0.0.1\n\n10.0.0.2:22,80 # ssh and web\n// 10.0.0.9\n", []string{"10.0.0.1"}, []string{"10.0.0.2:22", "10.0.0.2:80"}},
+	}
+	for _, tt := range tests {
+		hosts, hostPort := scanLines(t, strings.NewReader(tt.content))
+		if !reflect.DeepEqual(hosts, tt.hosts) {
+			t.Errorf("%s: hosts = %v, want %v", tt.name, hosts, tt.hosts)
+		}
+		if !reflect.DeepEqual(hostPort, tt.hostPort) {
+			t.Errorf("%s: HostPort = %v, want %v", tt.name, hostPort, tt.hostPort)
+		}
+	}
+}
+
+func TestScanIPFileStdin(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		hosts    []string
+		hostPort []string
+	}{
+		{"plain", []string{"10.0.0.1", "10.0.0.2"}, []string{"10.0.0.1", "10.0.0.2"}, nil},
+		{"host:port and comment", []string{"# from masscan", "10.0.0.3:443", "10.0.0.4"}, []string{"10.0.0.4"}, []string{"10.0.0.3:443"}},
+	}
+	for _, tt := range tests {
+		var stdin bytes.Buffer
+		for _, line := range tt.lines {
+			stdin.WriteString(line + "\n")
+		}
+		hosts, hostPort := scanLines(t, &stdin)
+		if !reflect.DeepEqual(hosts, tt.hosts) {
+			t.Errorf("%s: hosts = %v, want %v", tt.name, hosts, tt.hosts)
+		}
+		if !reflect.DeepEqual(hostPort, tt.hostPort) {
+			t.Errorf("%s: HostPort = %v, want %v", tt.name, hostPort, tt.hostPort)
+		}
+	}
+}
+
+func TestReadipfileStdin(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStdin := os.Stdin
+	os.Stdin = reader
+	defer func() { os.Stdin = oldStdin }()
+	go func() {
+		writer.WriteString("10.0.0.1\n# skip\n10.0.0.2\n")
+		writer.Close()
+	}()
+	hosts, err := Readipfile("-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10.0.0.1", "10.0.0.2"}; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("Readipfile(-) = %v, want %v", hosts, want)
+	}
+}
+
+func TestParseHostPortDedup(t *testing.T) {
+	tests := []struct {
+		name     string
+		ports    string
+		content  string
+		hosts    []string
+		hostPort []string
+	}{
+		{"overlapping ranges", "", "10.0.0.0/31:8080\n10.0.0.1-2:8080\n",
+			nil, []string{"10.0.0.0:8080", "10.0.0.1:8080", "10.0.0.2:8080"}},
+		{"repeated lines", "", "10.0.0.1:80,443\n10.0.0.1:443\n",
+			nil, []string{"10.0.0.1:80", "10.0.0.1:443"}},
+		{"covered by -p", "22,80", "10.0.0.5\n10.0.0.5:22\n10.0.0.5:8443\n10.0.0.6:80\n",
+			[]string{"10.0.0.5"}, []string{"10.0.0.5:8443", "10.0.0.6:80"}},
+	}
+	for _, tt := range tests {
+		res, err := (&Parser{Ports: tt.ports}).Parse("", writeHostFile(t, tt.content))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(res.Hosts, tt.hosts) {
+			t.Errorf("%s: hosts = %v, want %v", tt.name, res.Hosts, tt.hosts)
+		}
+		if !reflect.DeepEqual(res.HostPort, tt.hostPort) {
+			t.Errorf("%s: HostPort = %v, want %v", tt.name, res.HostPort, tt.hostPort)
+		}
+	}
+}
+
+func TestMergeHostPorts(t *testing.T) {
+	tests := []struct {
+		hosts, hostPorts []string
+		ports            string
+		want             []string
+	}{
+		{[]string{"10.0.0.1"}, []string{"10.0.0.1:80", "10.0.0.1:8080"}, "80", []string{"10.0.0.1:8080"}},
+		{[]string{"fe80::1"}, []string{"[fe80::1]:22", "[fe80::2]:22"}, "22", []string{"[fe80::2]:22"}},
+		{nil, []string{"10.0.0.1:80"}, "80", []string{"10.0.0.1:80"}},
+		{[]string{"10.0.0.1"}, []string{"10.0.0.1:80"}, "", []string{"10.0.0.1:80"}},
+	}
+	for _, tt := range tests {
+		if got := MergeHostPorts(tt.hosts, tt.hostPorts, tt.ports); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MergeHostPorts(%v, %v, %q) = %v, want %v", tt.hosts, tt.hostPorts, tt.ports, got, tt.want)
+		}
+	}
+}
+
+func TestParseIPChanMatchesParseIP(t *testing.T) {
+	oldPorts, oldN, oldM := Ports, RotateN, RotateM
+	defer func() { Ports, RotateN, RotateM, HostPort = oldPorts, oldN, oldM, nil }()
+	Ports = "22,80"
+	tests := []struct {
+		name    string
+		content string
+		rotate  int
+	}{
+		{"duplicates", "10.0.0.0/30\n10.0.0.1\n10.0.0.2-3\n", 0},
+		{"host:port merged", "10.0.0.5\n10.0.0.5:22\n10.0.0.5:8443\n10.0.0.6:80\n", 0},
+		{"exclude", "10.0.0.0/29\n!10.0.0.3\n", 0},
+		{"rotate", "10.1.0.0/26\n10.1.0.7\n", 2},
+	}
+	for _, tt := range tests {
+		RotateN, RotateM = 1, tt.rotate
+		file := writeHostFile(t, tt.content)
+		HostPort = nil
+		want, err := ParseIP("", file)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		wantHostPort := HostPort
+		HostPort = nil
+		stream, err := ParseIPChan("", file)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []string
+		for ip := range stream {
+			got = append(got, ip)
+		}
+		SortIPs(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: ParseIPChan = %v, ParseIP = %v", tt.name, got, want)
+		}
+		if !reflect.DeepEqual(HostPort, wantHostPort) {
+			t.Errorf("%s: ParseIPChan HostPort = %v, ParseIP HostPort = %v", tt.name, HostPort, wantHostPort)
+		}
+	}
+}
+
+func TestParseIP1Malformed(t *testing.T) {
+	tests := []string{
+		"1.1.1.1-",
+		"-1.1.1.1",
+		"1.1.1.1-2-3",
+		"-",
+		"1.1.1.1",
+		"1.1.1-5",
+		"1.1.1.1-256",
+		"1.1.1.1-x",
+		"1.1.1.1-1.1.1",
+	}
+	for _, in := range tests {
+		if _, _, err := ipRangeBounds(in); err == nil {
+			t.Errorf("ipRangeBounds(%q) got no error", in)
+		}
+		logger := &recordLogger{}
+		run := (&Parser{Logger: logger}).newRun(context.Background())
+		if got := run.parseIP1(in); got != nil {
+			t.Errorf("parseIP1(%q) = %v, want nil", in, got)
+		}
+		if !strings.Contains(logger.String(), in) {
+			t.Errorf("parseIP1(%q) logged %q, want the input in the error", in, logger.String())
+		}
+	}
+}
+
+func TestParseIP2Prefix(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"10.0.0.1/32", []string{"10.0.0.1"}},
+		{"10.0.0.0/31", []string{"10.0.0.0", "10.0.0.1"}},
+		{"10.0.0.1/31", []string{"10.0.0.0", "10.0.0.1"}},
+		{"10.0.0.0/30", []string{"10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3"}},
+		{"0.0.0.0/0", nil},
+		{"10.0.0.0/7", nil},
+		{"10.0.0.0/33", nil},
+	}
+	for _, tt := range tests {
+		if got := newTestRun().parseIP2(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseIP2(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseIPsInlineExclude(t *testing.T) {
+	tests := []struct {
+		in          string
+		count       int
+		first, last string
+		excluded    []string
+	}{
+		{"10.0.0.0/24!10.0.0.5", 255, "10.0.0.0", "10.0.0.255", []string{"10.0.0.5"}},
+		{"10.0.0.0/24!10.0.0.0/28", 240, "10.0.0.16", "10.0.0.255", []string{"10.0.0.0", "10.0.0.15"}},
+		{"10.0.0.0/24!10.0.0.1,10.0.0.254,10.0.1.1", 255, "10.0.0.0", "10.0.1.1", []string{"10.0.0.1", "10.0.0.254"}},
+		{"10.0.0.0/30!10.0.0.1!10.0.0.2", 2, "10.0.0.0", "10.0.0.3", []string{"10.0.0.1", "10.0.0.2"}},
+		{"10.0.0.0/30!10.0.0.0-2", 1, "10.0.0.3", "10.0.0.3", []string{"10.0.0.0", "10.0.0.2"}},
+		{"10.0.0.1-10.0.0.4!10.0.0.2,10.0.1.0/30", 7, "10.0.0.1", "10.0.1.3", []string{"10.0.0.2"}},
+	}
+	for _, tt := range tests {
+		got := ParseIPs(tt.in)
+		if len(got) != tt.count {
+			t.Errorf("ParseIPs(%q) got %d hosts, want %d", tt.in, len(got), tt.count)
+			continue
+		}
+		if got[0] != tt.first || got[len(got)-1] != tt.last {
+			t.Errorf("ParseIPs(%q) = %s..%s, want %s..%s", tt.in, got[0], got[len(got)-1], tt.first, tt.last)
+		}
+		for _, host := range got {
+			for _, excluded := range tt.excluded {
+				if host == excluded {
+					t.Errorf("ParseIPs(%q) still contains %s", tt.in, excluded)
+				}
+			}
+		}
+	}
+}
+
+func TestSortIPs(t *testing.T) {
+	tests := []struct {
+		in, want []string
+	}{
+		{[]string{"10.0.0.10", "10.0.0.2", "10.0.0.1"}, []string{"10.0.0.1", "10.0.0.2", "10.0.0.10"}},
+		{[]string{"10.0.1.0", "10.0.0.255", "9.255.255.255"}, []string{"9.255.255.255", "10.0.0.255", "10.0.1.0"}},
+		{[]string{"192.168.1.100", "192.168.1.20", "192.168.1.3"}, []string{"192.168.1.3", "192.168.1.20", "192.168.1.100"}},
+		{[]string{"web.local", "2001:db8::1", "10.0.0.1", "api.local"}, []string{"10.0.0.1", "2001:db8::1", "api.local", "web.local"}},
+		{[]string{"fe80::10%eth0", "fe80::2%eth0"}, []string{"fe80::2%eth0", "fe80::10%eth0"}},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		in := append([]string(nil), tt.in...)
+		if got := SortIPs(in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SortIPs(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseIPSorted(t *testing.T) {
+	hosts, err := ParseIP("10.0.0.10,10.0.0.9,10.0.0.1-3,10.0.0.100", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.9", "10.0.0.10", "10.0.0.100"}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("ParseIP = %v, want %v", hosts, want)
+	}
+}
+
+func TestParseIPInteger(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"3232235777", []string{"192.168.1.1"}},
+		{"0xC0A80101", []string{"192.168.1.1"}},
+		{"0xc0a80101", []string{"192.168.1.1"}},
+		{"167772161", []string{"10.0.0.1"}},
+		{"0", []string{"0.0.0.0"}},
+		{"4294967295", []string{"255.255.255.255"}},
+		{"4294967296", nil},
+		{"0x1C0A80101", []string{"0x1C0A80101"}},
+		{"web01", []string{"web01"}},
+	}
+	for _, tt := range tests {
+		if got := newTestRun().parseIP(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseIP(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDedupTargets(t *testing.T) {
+	old := ResolveMap
+	defer func() { ResolveMap = old }()
+	ResolveMap = map[string]string{"web.example.com": "10.0.0.5", "api.example.com": "10.0.0.5", "db.example.com": "10.0.0.6"}
+
+	tests := []struct {
+		name string
+		in   []Target
+		want []Target
+	}{
+		{"ip then hostname",
+			[]Target{{IP: "10.0.0.5"}, {IP: "web.example.com"}},
+			[]Target{{IP: "10.0.0.5", Names: []string{"web.example.com"}}}},
+		{"hostname then ip",
+			[]Target{{IP: "web.example.com"}, {IP: "10.0.0.5"}},
+			[]Target{{IP: "10.0.0.5", Names: []string{"web.example.com"}}}},
+		{"two names one ip",
+			[]Target{{IP: "web.example.com"}, {IP: "API.example.com"}, {IP: "10.0.0.5"}, {IP: "web.example.com"}},
+			[]Target{{IP: "10.0.0.5", Names: []string{"web.example.com", "API.example.com"}}}},
+		{"different ports kept",
+			[]Target{{IP: "10.0.0.6", Port: 80}, {IP: "db.example.com", Port: 3306}},
+			[]Target{{IP: "10.0.0.6", Port: 80}, {IP: "10.0.0.6", Port: 3306, Names: []string{"db.example.com"}}}},
+		{"unmapped hostname kept",
+			[]Target{{IP: "other.example.com"}, {IP: "10.0.0.5"}},
+			[]Target{{IP: "other.example.com"}, {IP: "10.0.0.5"}}},
+	}
+	for _, tt := range tests {
+		if got := dedupTargets(tt.in, nil); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: dedupTargets = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseDedupHostnames(t *testing.T) {
+	old := ResolveMap
+	defer func() { ResolveMap = old }()
+	ResolveMap = map[string]string{"web.example.com": "10.0.0.5"}
+
+	res, err := (&Parser{}).Parse("web.example.com,10.0.0.4-6", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10.0.0.4", "10.0.0.5", "10.0.0.6"}; !reflect.DeepEqual(res.Hosts, want) {
+		t.Errorf("hosts = %v, want %v", res.Hosts, want)
+	}
+	if want := []string{"web.example.com"}; !reflect.DeepEqual(res.Aliases["10.0.0.5"], want) {
+		t.Errorf("aliases = %v, want %v", res.Aliases, want)
+	}
+	if res.Names["10.0.0.5"] != "web.example.com" {
+		t.Errorf("names = %v, want 10.0.0.5 -> web.example.com", res.Names)
+	}
+}
+
+func TestDedupDnsResolveNames(t *testing.T) {
+	old := ResolvedNames
+	defer func() { ResolvedNames = old }()
+	// 之前的解析已并入全局的记录,本次解析的记录还在 names 中
+	ResolvedNames = map[string]string{"10.0.0.7": "old.example.com"}
+	names := map[string]string{"10.0.0.6": "web.example.com", "10.0.0.5": "web.example.com"}
+
+	in := []Target{{IP: "WEB.example.com"}, {IP: "10.0.0.5"}, {IP: "old.example.com"}, {IP: "10.0.0.7"}, {IP: "other.example.com"}}
+	want := []Target{
+		{IP: "10.0.0.5", Names: []string{"WEB.example.com"}},
+		{IP: "10.0.0.7", Names: []string{"old.example.com"}},
+		{IP: "other.example.com"},
+	}
+	if got := dedupTargets(in, names); !reflect.DeepEqual(got, want) {
+		t.Errorf("dedupTargets = %+v, want %+v", got, want)
+	}
+
+	hosts := newHostSet()
+	hosts.add("10.0.0.5")
+	got := mergeHostPorts(hosts, []string{"web.example.com:80", "web.example.com:8080", "other.example.com:80"}, "80", names)
+	if want := []string{"web.example.com:8080", "other.example.com:80"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeHostPorts = %v, want %v", got, want)
+	}
+}
+
+func TestSplitTargetLine(t *testing.T) {
+	tests := []struct {
+		line        string
+		host, ports string
+		hasPort     bool
+	}{
+		{"10.0.0.1:80", "10.0.0.1", "80", true},
+		{"10.0.0.1:80\textra words", "10.0.0.1", "80", true},
+		{"10.0.0.1:80 , 443", "10.0.0.1", "80,443", true},
+		{"\t10.0.0.1\t", "10.0.0.1", "", false},
+		{"10.0.0.1\r", "10.0.0.1", "", false},
+		{"10.0.0.1:8080\r", "10.0.0.1", "8080", true},
+		{"[2001:db8::1]:443\r", "2001:db8::1", "443", true},
+		{"[2001:db8::1]", "2001:db8::1", "", false},
+		{"2001:db8::1", "2001:db8::1", "", false},
+		{"", "", "", false},
+	}
+	for _, tt := range tests {
+		host, ports, hasPort := splitTargetLine(tt.line)
+		if host != tt.host || ports != tt.ports || hasPort != tt.hasPort {
+			t.Errorf("splitTargetLine(%q) = %q, %q, %v, want %q, %q, %v", tt.line, host, ports, hasPort, tt.host, tt.ports, tt.hasPort)
+		}
+	}
+}
+
+func TestScanIPFileWhitespace(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		hosts    []string
+		hostPort []string
+	}{
+		{"crlf", "10.0.0.1\r\n10.0.0.2:22\r\n\r\n", []string{"10.0.0.1"}, []string{"10.0.0.2:22"}},
+		{"tab separated", "10.0.0.1\tweb\n10.0.0.2:80\tadmin panel\n", []string{"10.0.0.1"}, []string{"10.0.0.2:80"}},
+		{"trailing spaces", "10.0.0.1:443   \n  10.0.0.3  \n", []string{"10.0.0.3"}, []string{"10.0.0.1:443"}},
+		{"crlf ipv6", "[2001:db8::1]:8443\r\n", nil, []string{"[2001:db8::1]:8443"}},
+		{"bad port", "10.0.0.1:\r\n10.0.0.2:abc\n10.0.0.4\n", []string{"10.0.0.4"}, nil},
+	}
+	for _, tt := range tests {
+		hosts, hostPort := scanLines(t, strings.NewReader(tt.content))
+		if !reflect.DeepEqual(hosts, tt.hosts) {
+			t.Errorf("%s: hosts = %v, want %v", tt.name, hosts, tt.hosts)
+		}
+		if !reflect.DeepEqual(hostPort, tt.hostPort) {
+			t.Errorf("%s: HostPort = %v, want %v", tt.name, hostPort, tt.hostPort)
+		}
+	}
+}
+
+func TestSkipNetBroadcast(t *testing.T) {
+	old := SkipNetBroadcast
+	defer func() { SkipNetBroadcast = old }()
+
+	tests := []struct {
+		cidr        string
+		skip        bool
+		count       int
+		first, last string
+		kept        []string
+	}{
+		{"10.0.0.0/24", false, 256, "10.0.0.0", "10.0.0.255", nil},
+		{"10.0.0.0/24", true, 254, "10.0.0.1", "10.0.0.254", nil},
+		{"10.0.0.0/23", true, 510, "10.0.0.1", "10.0.1.254", []string{"10.0.0.255", "10.0.1.0"}},
+		{"10.0.0.0/30", true, 2, "10.0.0.1", "10.0.0.2", nil},
+		{"10.0.0.0/31", true, 2, "10.0.0.0", "10.0.0.1", nil},
+		{"10.0.0.7/32", true, 1, "10.0.0.7", "10.0.0.7", nil},
+	}
+	for _, tt := range tests {
+		SkipNetBroadcast = tt.skip
+		// 列表展开和流式展开两条路径结果一致
+		res, err := (&Parser{}).Parse(tt.cidr, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, got := range [][]string{newTestRun().parseIP2(tt.cidr), res.Hosts} {
+			if len(got) != tt.count {
+				t.Errorf("%s skip=%v got %d hosts, want %d", tt.cidr, tt.skip, len(got), tt.count)
+				continue
+			}
+			if got[0] != tt.first || got[len(got)-1] != tt.last {
+				t.Errorf("%s skip=%v = %s..%s, want %s..%s", tt.cidr, tt.skip, got[0], got[len(got)-1], tt.first, tt.last)
+			}
+			for _, want := range tt.kept {
+				found := false
+				for _, host := range got {
+					found = found || host == want
+				}
+				if !found {
+					t.Errorf("%s skip=%v dropped %s, only the network and broadcast should go", tt.cidr, tt.skip, want)
+				}
+			}
+		}
+	}
+}
+
+func TestParseURLTargets(t *testing.T) {
+	tests := []struct {
+		in       string
+		hosts    []string
+		hostPort []string
+		urls     map[string]string
+	}{
+		{"http://host", nil, []string{"host:80"}, map[string]string{"host:80": "http://host"}},
+		{"https://host:8443/x", nil, []string{"host:8443"}, map[string]string{"host:8443": "https://host:8443/x"}},
+		{"https://10.0.0.5/admin?a=1", nil, []string{"10.0.0.5:443"}, map[string]string{"10.0.0.5:443": "https://10.0.0.5/admin?a=1"}},
+		{"http://[2001:db8::1]:8080/", nil, []string{"[2001:db8::1]:8080"}, map[string]string{"[2001:db8::1]:8080": "http://[2001:db8::1]:8080/"}},
+		{"10.0.0.5", []string{"10.0.0.5"}, nil, nil},
+		{"10.0.0.5,http://host", []string{"10.0.0.5"}, []string{"host:80"}, map[string]string{"host:80": "http://host"}},
+		{"ftp://host", nil, nil, nil},
+	}
+	for _, tt := range tests {
+		res, _ := (&Parser{}).Parse(tt.in, "")
+		if !reflect.DeepEqual(res.Hosts, tt.hosts) {
+			t.Errorf("Parse(%q) hosts = %v, want %v", tt.in, res.Hosts, tt.hosts)
+		}
+		if len(res.HostPort)+len(tt.hostPort) > 0 && !reflect.DeepEqual(res.HostPort, tt.hostPort) {
+			t.Errorf("Parse(%q) HostPort = %v, want %v", tt.in, res.HostPort, tt.hostPort)
+		}
+		if len(res.URLs)+len(tt.urls) > 0 && !reflect.DeepEqual(res.URLs, tt.urls) {
+			t.Errorf("Parse(%q) URLs = %v, want %v", tt.in, res.URLs, tt.urls)
+		}
+	}
+}
+
+func TestParseURLTarget(t *testing.T) {
+	tests := []struct {
+		in      string
+		host    string
+		port    int
+		wantErr bool
+	}{
+		{"http://host", "host", 80, false},
+		{"HTTPS://host/x", "host", 443, false},
+		{"https://host:8443/x", "host", 8443, false},
+		{"redis://host:6379", "host", 6379, false},
+		{"redis://host", "", 0, true},
+		{"http://host:0", "", 0, true},
+		{"http://:80", "", 0, true},
+	}
+	for _, tt := range tests {
+		u, port, err := parseURLTarget(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseURLTarget(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (u.Hostname() != tt.host || port != tt.port) {
+			t.Errorf("parseURLTarget(%q) = %s, %d, want %s, %d", tt.in, u.Hostname(), port, tt.host, tt.port)
+		}
+	}
+}
+
+// 已启用的回环网卡名,找不到时跳过依赖它的用例
+func loopbackName(t *testing.T) string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 && iface.Flags&net.FlagUp != 0 {
+			return iface.Name
+		}
+	}
+	t.Skip("no loopback interface")
+	return ""
+}
+
+func TestLocalTargets(t *testing.T) {
+	lo := loopbackName(t)
+	tests := []struct {
+		target  string
+		want    string
+		wantErr bool
+	}{
+		{"local:" + lo, "127.0.0.0/8", false},
+		{"local:no-such-if0", "", true},
+	}
+	for _, tt := range tests {
+		cidrs, err := LocalCIDRs(tt.target)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("LocalCIDRs(%q) err = %v, wantErr %v", tt.target, err, tt.wantErr)
+			continue
+		}
+		found := tt.want == ""
+		for _, cidr := range cidrs {
+			found = found || cidr == tt.want
+		}
+		if !found {
+			t.Errorf("LocalCIDRs(%q) = %v, want it to contain %s", tt.target, cidrs, tt.want)
+		}
+	}
+	if cidrs, _ := LocalCIDRs("local"); len(cidrs) > 0 {
+		for _, cidr := range cidrs {
+			if cidr == "127.0.0.0/8" {
+				t.Errorf("LocalCIDRs(local) = %v, loopback should be skipped", cidrs)
+			}
+		}
+	}
+
+	// local:网卡 得到的网段走普通的展开路径,回环的/8照常抽样
+	logger := &recordLogger{}
+	run := (&Parser{Logger: logger}).newRun(context.Background())
+	hosts := run.parseIP("local:" + lo)
+	if len(hosts) == 0 {
+		t.Errorf("parseIP(local:%s) got no hosts", lo)
+	}
+	loNet := &net.IPNet{IP: net.IPv4(127, 0, 0, 0), Mask: net.CIDRMask(8, 32)}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip == nil || ip.To4() != nil && !loNet.Contains(ip) {
+			t.Errorf("parseIP(local:%s) got %s outside the interface cidrs", lo, host)
+			break
+		}
+	}
+	if !strings.Contains(logger.String(), "127.0.0.0/8") {
+		t.Errorf("parseIP(local:%s) logged %q, want the derived cidr", lo, logger.String())
+	}
+	if hosts := run.parseIP("local:no-such-if0"); hosts != nil {
+		t.Errorf("parseIP(local:no-such-if0) = %v, want nil", hosts)
+	}
+}
+
+func TestIPRange(t *testing.T) {
+	tests := []struct {
+		name string
+		net  *net.IPNet
+		want string
+	}{
+		{"ipv4", &net.IPNet{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(24, 32)}, "10.0.0.0-10.0.0.255"},
+		{"16-byte ip, 4-byte mask", &net.IPNet{IP: net.ParseIP("192.168.0.0"), Mask: net.CIDRMask(16, 32)}, "192.168.0.0-192.168.255.255"},
+		{"16-byte ip, 16-byte mask", &net.IPNet{IP: net.ParseIP("172.16.0.0"), Mask: net.CIDRMask(108, 128)}, "172.16.0.0-172.31.255.255"},
+		{"4-byte ip, 16-byte mask", &net.IPNet{IP: net.IP{10, 1, 2, 0}, Mask: net.CIDRMask(120, 128)}, "10.1.2.0-10.1.2.255"},
+		{"ipv6", &net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(120, 128)}, "2001:db8::-2001:db8::ff"},
+		{"ipv4 with short ipv6 mask", &net.IPNet{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(64, 128)}, ""},
+		{"ipv6 with ipv4 mask", &net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(24, 32)}, ""},
+	}
+	for _, tt := range tests {
+		if got := IPRange(tt.net); got != tt.want {
+			t.Errorf("%s: IPRange(%v/%v) = %q, want %q", tt.name, tt.net.IP, tt.net.Mask, got, tt.want)
+		}
+	}
+	_, ipNet, _ := net.ParseCIDR("10.0.0.0/24")
+	if got := IPRange(ipNet); got != "10.0.0.0-10.0.0.255" {
+		t.Errorf("IPRange(ParseCIDR(10.0.0.0/24)) = %q", got)
+	}
+}
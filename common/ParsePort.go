@@ -1,10 +1,74 @@
 package common
 
 import (
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// 严格解析端口: 逗号列表、a-b 范围和 PortGroup 中的预设名(top100、web、db等),
+// 去重并排序,任何非法片段都返回指向该片段的错误
+func ParsePorts(spec string) ([]int, error) {
+	seen := make(map[int]struct{})
+	var ports []int
+	add := func(port int) {
+		if _, ok := seen[port]; !ok {
+			seen[port] = struct{}{}
+			ports = append(ports, port)
+		}
+	}
+	for _, token := range strings.Split(spec, ",") {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		if group, ok := PortGroup[token]; ok {
+			groupPorts, err := ParsePorts(group)
+			if err != nil {
+				return nil, err
+			}
+			for _, port := range groupPorts {
+				add(port)
+			}
+			continue
+		}
+		start, end, err := parsePortToken(token)
+		if err != nil {
+			return nil, err
+		}
+		for port := start; port <= end; port++ {
+			add(port)
+		}
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no port in %q", spec)
+	}
+	sort.Ints(ports)
+	return ports, nil
+}
+
+func parsePortToken(token string) (int, int, error) {
+	parts := strings.SplitN(token, "-", 2)
+	start, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port %q, use a number, a-b range or preset like top100|web|db", token)
+	}
+	end := start
+	if len(parts) == 2 {
+		if end, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil {
+			return 0, 0, fmt.Errorf("invalid port range %q", token)
+		}
+	}
+	if start < 1 || start > 65535 || end < 1 || end > 65535 {
+		return 0, 0, fmt.Errorf("port %q out of range 1-65535", token)
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("invalid port range %q, start > end", token)
+	}
+	return start, end, nil
+}
+
 func ParsePort(ports string) (scanPorts []int) {
 	if ports == "" {
 		return
@@ -50,6 +114,44 @@ func ParsePort(ports string) (scanPorts []int) {
 	return scanPorts
 }
 
+// 从端口列表中去掉exclude中的端口,exclude支持与 -p 相同的列表、范围和预设写法,保持原有顺序
+func FilterPorts(ports []int, exclude string) []int {
+	excluded := ParsePort(exclude)
+	if len(excluded) == 0 {
+		return ports
+	}
+	skip := make(map[int]struct{}, len(excluded))
+	for _, port := range excluded {
+		skip[port] = struct{}{}
+	}
+	var result []int
+	for _, port := range ports {
+		if _, ok := skip[port]; !ok {
+			result = append(result, port)
+		}
+	}
+	return result
+}
+
+// 对 host:port 目标做同样的端口排除
+func FilterHostPorts(targets []string, exclude string) []string {
+	excluded := ParsePort(exclude)
+	if len(excluded) == 0 {
+		return targets
+	}
+	skip := make(map[string]struct{}, len(excluded))
+	for _, port := range excluded {
+		skip[strconv.Itoa(port)] = struct{}{}
+	}
+	var result []string
+	for _, target := range targets {
+		if _, ok := skip[target[strings.LastIndex(target, ":")+1:]]; !ok {
+			result = append(result, target)
+		}
+	}
+	return result
+}
+
 func removeDuplicate(old []int) []int {
 	result := []int{}
 	temp := map[int]struct{}{}
@@ -0,0 +1,93 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []int
+		wantErr bool
+	}{
+		{"80", []int{80}, false},
+		{"80,443", []int{80, 443}, false},
+		{"443,80,80", []int{80, 443}, false},
+		{"80-82,443", []int{80, 81, 82, 443}, false},
+		{" 22 , 8000-8001 ", []int{22, 8000, 8001}, false},
+		{"", nil, true},
+		{"0", nil, true},
+		{"65536", nil, true},
+		{"82-80", nil, true},
+		{"80-", nil, true},
+		{"http", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePorts(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePorts(%q) err = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePorts(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+	if got, err := ParsePorts("1-1024"); err != nil || len(got) != 1024 || got[0] != 1 || got[1023] != 1024 {
+		t.Errorf("ParsePorts(1-1024) = %d ports, err %v", len(got), err)
+	}
+}
+
+func TestParseHostPorts(t *testing.T) {
+	tests := []struct {
+		host    string
+		want    []string
+		wantErr bool
+	}{
+		{"1.1.1.1:80,443", []string{"1.1.1.1:80", "1.1.1.1:443"}, false},
+		{"host:80-82,443", []string{"host:80", "host:81", "host:82", "host:443"}, false},
+		{"10.0.0.0/31:22", []string{"10.0.0.0:22", "10.0.0.1:22"}, false},
+		{"[2001:db8::1]:80,443", []string{"[2001:db8::1]:80", "[2001:db8::1]:443"}, false},
+		{"1.1.1.1:", nil, true},
+		{"1.1.1.1:0", nil, true},
+		{"1.1.1.1:80-", nil, true},
+		{"[2001:db8::1]:", nil, true},
+	}
+	for _, tt := range tests {
+		res, err := (&Parser{}).Parse(tt.host, "")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) err = %v, wantErr %v", tt.host, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(res.HostPort, tt.want) {
+			t.Errorf("Parse(%q) HostPort = %v, want %v", tt.host, res.HostPort, tt.want)
+		}
+	}
+	res, err := (&Parser{}).Parse("1.1.1.1:1-1024", "")
+	if err != nil || len(res.HostPort) != 1024 || res.HostPort[0] != "1.1.1.1:1" || res.HostPort[1023] != "1.1.1.1:1024" {
+		t.Errorf("Parse(1.1.1.1:1-1024) = %d targets, err %v", len(res.HostPort), err)
+	}
+}
+
+func TestSplitHostPorts(t *testing.T) {
+	tests := []struct {
+		target     string
+		host, port string
+		ok         bool
+	}{
+		{"1.1.1.1:80,443", "1.1.1.1", "80,443", true},
+		{"[::1]:8080", "::1", "8080", true},
+		{"::1", "", "", false},
+		{"fe80::1%eth0", "", "", false},
+		{"local:eth0", "", "", false},
+		{"private:10", "", "", false},
+		{"http://1.1.1.1:8080", "", "", false},
+		{"1.1.1.1", "", "", false},
+	}
+	for _, tt := range tests {
+		host, port, ok := splitHostPorts(tt.target)
+		if host != tt.host || port != tt.port || ok != tt.ok {
+			t.Errorf("splitHostPorts(%q) = %q, %q, %v, want %q, %q, %v", tt.target, host, port, ok, tt.host, tt.port, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package common
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 常见CDN/WAF厂商公开的IPv4段,扫这些地址只会打到边缘节点
+var CDNRanges = map[string][]string{
+	"cloudflare": {"173.245.48.0/20", "103.21.244.0/22", "103.22.200.0/22", "103.31.4.0/22", "141.101.64.0/18", "108.162.192.0/18", "190.93.240.0/20", "188.114.96.0/20", "197.234.240.0/22", "198.41.128.0/17", "162.158.0.0/15", "104.16.0.0/13", "104.24.0.0/14", "172.64.0.0/13", "131.0.72.0/22"},
+	"fastly":     {"23.235.32.0/20", "43.249.72.0/22", "103.244.50.0/24", "103.245.222.0/23", "103.245.224.0/24", "104.156.80.0/20", "140.248.64.0/18", "140.248.128.0/17", "146.75.0.0/17", "151.101.0.0/16", "157.52.64.0/18", "167.82.0.0/17", "167.82.128.0/20", "167.82.160.0/20", "167.82.224.0/20", "172.111.64.0/18", "185.31.16.0/22", "199.27.72.0/21", "199.232.0.0/16"},
+	"akamai":     {"2.16.0.0/13", "23.0.0.0/12", "23.32.0.0/11", "23.64.0.0/14", "23.192.0.0/11", "95.100.0.0/15", "104.64.0.0/10", "184.24.0.0/13", "184.50.0.0/15", "184.84.0.0/14"},
+	"cloudfront": {"13.32.0.0/15", "13.224.0.0/14", "18.64.0.0/14", "52.84.0.0/15", "54.182.0.0/16", "54.192.0.0/16", "54.230.0.0/16", "54.239.128.0/18", "99.84.0.0/16", "143.204.0.0/16", "204.246.164.0/22", "205.251.192.0/19"},
+}
+
+// 响应头特征
+var cdnHeaders = []struct {
+	provider, header, value string
+}{
+	{"cloudflare", "Cf-Ray", ""},
+	{"cloudflare", "Server", "cloudflare"},
+	{"cloudfront", "X-Amz-Cf-Id", ""},
+	{"akamai", "Server", "AkamaiGHost"},
+	{"fastly", "X-Served-By", "cache-"},
+	{"fastly", "X-Fastly-Request-Id", ""},
+	{"sucuri", "X-Sucuri-Id", ""},
+	{"incapsula", "X-Iinfo", ""},
+}
+
+type cdnRange struct {
+	start, end uint32
+	provider   string
+}
+
+var (
+	cdnTable []cdnRange
+	cdnOnce  sync.Once
+)
+
+func initCDN() {
+	for provider, cidrs := range CDNRanges {
+		for _, cidr := range cidrs {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			start := binary.BigEndian.Uint32(ipNet.IP.To4())
+			ones, _ := ipNet.Mask.Size()
+			cdnTable = append(cdnTable, cdnRange{start, start | (1<<(32-ones) - 1), provider})
+		}
+	}
+	sort.Slice(cdnTable, func(i, j int) bool { return cdnTable[i].start < cdnTable[j].start })
+}
+
+// 返回ip所属的CDN厂商,不属于则返回空
+func CDNProvider(host string) string {
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return ""
+	}
+	cdnOnce.Do(initCDN)
+	num := binary.BigEndian.Uint32(ip)
+	i := sort.Search(len(cdnTable), func(i int) bool { return cdnTable[i].start > num })
+	if i > 0 && num <= cdnTable[i-1].end {
+		return cdnTable[i-1].provider
+	}
+	return ""
+}
+
+func CDNHeader(header http.Header) string {
+	for _, h := range cdnHeaders {
+		if value := header.Get(h.header); value != "" && strings.Contains(strings.ToLower(value), strings.ToLower(h.value)) {
+			return h.provider
+		}
+	}
+	return ""
+}
+
+// 统计目标中属于CDN/WAF的数量,开启 -skip-cdn 时去掉
+func FilterCDN(hosts []string) []string {
+	return filterCDN(hosts, func(format string, a ...interface{}) {
+		fmt.Printf(format+"\n", a...)
+	})
+}
+
+func filterCDN(hosts []string, logf func(format string, a ...interface{})) []string {
+	count := make(map[string]int)
+	var result []string
+	var total int
+	for _, host := range hosts {
+		if provider := CDNProvider(host); provider != "" {
+			count[provider]++
+			total++
+			if SkipCDN {
+				continue
+			}
+		}
+		result = append(result, host)
+	}
+	logCDN(count, total, logf)
+	return result
+}
+
+// 按厂商汇总输出命中CDN/WAF段的目标数
+func logCDN(count map[string]int, total int, logf func(format string, a ...interface{})) {
+	if total > 0 {
+		var detail []string
+		for provider, num := range count {
+			detail = append(detail, fmt.Sprintf("%s:%d", provider, num))
+		}
+		sort.Strings(detail)
+		if SkipCDN {
+			logf("[*] %d targets are CDN/WAF fronted (%s), skipped", total, strings.Join(detail, " "))
+		} else {
+			logf("[*] %d targets are CDN/WAF fronted (%s), use -skip-cdn to skip them", total, strings.Join(detail, " "))
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package common
 
+import (
+	"context"
+	"time"
+)
+
 var version = "1.8.4"
 var Userdict = map[string][]string{
 	"ftp":        {"ftp", "admin", "www", "web", "root", "db", "wwwroot", "data"},
@@ -14,17 +19,23 @@ var Userdict = map[string][]string{
 }
 
 var Passwords = []string{"123456", "admin", "admin123", "root", "", "pass123", "pass@123", "password", "123123", "654321", "111111", "123", "1", "admin@123", "Admin@123", "admin123!@#", "{user}", "{user}1", "{user}111", "{user}123", "{user}@123", "{user}_123", "{user}#123", "{user}@111", "{user}@2019", "{user}@123#4", "P@ssw0rd!", "P@ssw0rd", "Passw0rd", "qwe123", "12345678", "test", "test123", "123qwe", "123qwe!@#", "123456789", "123321", "666666", "a123456.", "123456~a", "123456!a", "000000", "1234567890", "8888888", "!QAZ2wsx", "1qaz2wsx", "abc123", "abc123456", "1qaz@WSX", "a11111", "a12345", "Aa1234", "Aa1234.", "Aa12345", "a123456", "a123123", "Aa123123", "Aa123456", "Aa12345.", "sysadmin", "system", "1qaz!QAZ", "2wsx@WSX", "qwe123!@#", "Aa123456!", "A123456s!", "sa123456", "1q2w3e", "Charge123", "Aa123456789"}
+
+// 按泄露库中出现频率排序的常见口令,用于 -pwd-sort 时优先尝试
+var PasswordRank = []string{"123456", "password", "123456789", "12345678", "{user}", "admin", "12345", "111111", "1234567", "123123", "", "root", "000000", "{user}123", "admin123", "1234567890", "abc123", "123", "1", "654321", "666666", "8888888", "1qaz2wsx", "qwe123", "{user}@123", "admin@123", "Admin@123", "P@ssw0rd", "Passw0rd", "Aa123456", "test", "test123", "123qwe", "a123456", "1qaz@WSX", "!QAZ2wsx", "pass123", "{user}1", "{user}@2019", "123321"}
+
 var PORTList = map[string]int{
 	"ftp":         21,
 	"ssh":         22,
 	"findnet":     135,
 	"netbios":     139,
+	"snmp":        161,
 	"smb":         445,
 	"mssql":       1433,
 	"oracle":      1521,
 	"mysql":       3306,
 	"rdp":         3389,
 	"psql":        5432,
+	"sip":         5060,
 	"redis":       6379,
 	"fcgi":        9000,
 	"mem":         11211,
@@ -36,6 +47,8 @@ var PORTList = map[string]int{
 	"webpoc":      1000003,
 	"smb2":        1000004,
 	"wmiexec":     1000005,
+	"proxy":       1000006,
+	"inventory":   1000007,
 	"all":         0,
 	"portscan":    0,
 	"icmp":        0,
@@ -46,27 +59,47 @@ var PortGroup = map[string]string{
 	"ssh":         "22",
 	"findnet":     "135",
 	"netbios":     "139",
+	"snmp":        "161",
 	"smb":         "445",
 	"mssql":       "1433",
 	"oracle":      "1521",
 	"mysql":       "3306",
 	"rdp":         "3389",
 	"psql":        "5432",
+	"sip":         "5060,5061",
 	"redis":       "6379",
 	"fcgi":        "9000",
 	"mem":         "11211",
 	"mgo":         "27017",
 	"ms17010":     "445",
 	"cve20200796": "445",
+	"proxy":       "1080,1081,3128,7890,8118,10808",
+	"mail":        "25,110,143,465,587,993,995",
 	"service":     "21,22,135,139,445,1433,1521,3306,3389,5432,6379,9000,11211,27017",
 	"db":          "1433,1521,3306,5432,6379,11211,27017",
-	"web":         "80,81,82,83,84,85,86,87,88,89,90,91,92,98,99,443,800,801,808,880,888,889,1000,1010,1080,1081,1082,1099,1118,1888,2008,2020,2100,2375,2379,3000,3008,3128,3505,5555,6080,6648,6868,7000,7001,7002,7003,7004,7005,7007,7008,7070,7071,7074,7078,7080,7088,7200,7680,7687,7688,7777,7890,8000,8001,8002,8003,8004,8006,8008,8009,8010,8011,8012,8016,8018,8020,8028,8030,8038,8042,8044,8046,8048,8053,8060,8069,8070,8080,8081,8082,8083,8084,8085,8086,8087,8088,8089,8090,8091,8092,8093,8094,8095,8096,8097,8098,8099,8100,8101,8108,8118,8161,8172,8180,8181,8200,8222,8244,8258,8280,8288,8300,8360,8443,8448,8484,8800,8834,8838,8848,8858,8868,8879,8880,8881,8888,8899,8983,8989,9000,9001,9002,9008,9010,9043,9060,9080,9081,9082,9083,9084,9085,9086,9087,9088,9089,9090,9091,9092,9093,9094,9095,9096,9097,9098,9099,9100,9200,9443,9448,9800,9981,9986,9988,9998,9999,10000,10001,10002,10004,10008,10010,10250,12018,12443,14000,16080,18000,18001,18002,18004,18008,18080,18082,18088,18090,18098,19001,20000,20720,21000,21501,21502,28018,20880",
+	"web":         "80,81,82,83,84,85,86,87,88,89,90,91,92,98,99,443,800,801,808,880,888,889,1000,1010,1080,1081,1082,1099,1118,1888,2008,2020,2100,2375,2379,3000,3008,3128,3505,5555,6080,6648,6868,7000,7001,7002,7003,7004,7005,7007,7008,7070,7071,7074,7078,7080,7088,7200,7680,7687,7688,7777,7890,8000,8001,8002,8003,8004,8006,8008,8009,8010,8011,8012,8016,8018,8020,8028,8030,8038,8042,8044,8046,8048,8053,8060,8069,8070,8080,8081,8082,8083,8084,8085,8086,8087,8088,8089,8090,8091,8092,8093,8094,8095,8096,8097,8098,8099,8100,8101,8108,8118,8161,8172,8180,8181,8200,8222,8244,8258,8280,8288,8300,8360,8443,8448,8484,8800,8834,8838,8848,8858,8868,8879,8880,8881,8888,8899,8983,8989,9000,9001,9002,9008,9010,9043,9060,9080,9081,9082,9083,9084,9085,9086,9087,9088,9089,9090,9091,9092,9093,9094,9095,9096,9097,9098,9099,9100,9200,9443,9448,9800,9981,9986,9988,9998,9999,10000,10001,10002,10004,10008,10010,10250,12018,12443,14000,16080,18000,18001,18002,18004,18008,18080,18082,18088,18090,18098,19001,20000,20720,21000,21501,21502,28018,20880,9870,19888,50070",
+	"top100":      "7,9,13,21,22,23,25,26,37,53,79,80,81,88,106,110,111,113,119,135,139,143,144,179,199,389,427,443,444,445,465,513,514,515,543,544,548,554,587,631,646,873,990,993,995,1025,1026,1027,1028,1029,1110,1433,1720,1723,1755,1900,2000,2001,2049,2121,2717,3000,3128,3306,3389,3986,4899,5000,5009,5051,5060,5101,5190,5357,5432,5631,5666,5800,5900,6000,6001,6646,7070,8000,8008,8009,8080,8081,8443,8888,9100,9999,10000,32768,49152,49153,49154,49155,49156,49157",
 	"all":         "1-65535",
 	"main":        "21,22,80,81,135,139,443,445,1433,1521,3306,5432,6379,7001,8000,8080,8089,9000,9200,11211,27017",
 }
+
+// -group 服务组,同时决定扫描的端口和启用的插件,端口由组自身和组内插件的PortGroup合并而来
+// db:     mssql,oracle,mysql,psql,redis,mem,mgo
+// web:    webtitle+poc,端口同 -m web
+// remote: ssh,rdp,smb
+// mail:   25,110,143,465,587,993,995,暂无对应插件,只做端口探测
+// infra:  ftp,findnet,netbios,ms17010,sip,proxy
+var ServiceGroup = map[string][]string{
+	"db":     {"mssql", "oracle", "mysql", "psql", "redis", "mem", "mgo"},
+	"web":    {"web"},
+	"remote": {"ssh", "rdp", "smb"},
+	"mail":   {},
+	"infra":  {"ftp", "findnet", "netbios", "ms17010", "sip", "proxy"},
+}
+
 var Outputfile = "result.txt"
 var IsSave = true
-var Webport = "80,81,82,83,84,85,86,87,88,89,90,91,92,98,99,443,800,801,808,880,888,889,1000,1010,1080,1081,1082,1099,1118,1888,2008,2020,2100,2375,2379,3000,3008,3128,3505,5555,6080,6648,6868,7000,7001,7002,7003,7004,7005,7007,7008,7070,7071,7074,7078,7080,7088,7200,7680,7687,7688,7777,7890,8000,8001,8002,8003,8004,8006,8008,8009,8010,8011,8012,8016,8018,8020,8028,8030,8038,8042,8044,8046,8048,8053,8060,8069,8070,8080,8081,8082,8083,8084,8085,8086,8087,8088,8089,8090,8091,8092,8093,8094,8095,8096,8097,8098,8099,8100,8101,8108,8118,8161,8172,8180,8181,8200,8222,8244,8258,8280,8288,8300,8360,8443,8448,8484,8800,8834,8838,8848,8858,8868,8879,8880,8881,8888,8899,8983,8989,9000,9001,9002,9008,9010,9043,9060,9080,9081,9082,9083,9084,9085,9086,9087,9088,9089,9090,9091,9092,9093,9094,9095,9096,9097,9098,9099,9100,9200,9443,9448,9800,9981,9986,9988,9998,9999,10000,10001,10002,10004,10008,10010,10250,12018,12443,14000,16080,18000,18001,18002,18004,18008,18080,18082,18088,18090,18098,19001,20000,20720,21000,21501,21502,28018,20880"
+var Webport = "80,81,82,83,84,85,86,87,88,89,90,91,92,98,99,443,800,801,808,880,888,889,1000,1010,1080,1081,1082,1099,1118,1888,2008,2020,2100,2375,2379,3000,3008,3128,3505,5555,6080,6648,6868,7000,7001,7002,7003,7004,7005,7007,7008,7070,7071,7074,7078,7080,7088,7200,7680,7687,7688,7777,7890,8000,8001,8002,8003,8004,8006,8008,8009,8010,8011,8012,8016,8018,8020,8028,8030,8038,8042,8044,8046,8048,8053,8060,8069,8070,8080,8081,8082,8083,8084,8085,8086,8087,8088,8089,8090,8091,8092,8093,8094,8095,8096,8097,8098,8099,8100,8101,8108,8118,8161,8172,8180,8181,8200,8222,8244,8258,8280,8288,8300,8360,8443,8448,8484,8800,8834,8838,8848,8858,8868,8879,8880,8881,8888,8899,8983,8989,9000,9001,9002,9008,9010,9043,9060,9080,9081,9082,9083,9084,9085,9086,9087,9088,9089,9090,9091,9092,9093,9094,9095,9096,9097,9098,9099,9100,9200,9443,9448,9800,9981,9986,9988,9998,9999,10000,10001,10002,10004,10008,10010,10250,12018,12443,14000,16080,18000,18001,18002,18004,18008,18080,18082,18088,18090,18098,19001,20000,20720,21000,21501,21502,28018,20880,9870,19888,50070"
 var DefaultPorts = "21,22,80,81,135,139,443,445,1433,1521,3306,5432,6379,7001,8000,8080,8089,9000,9200,11211,27017"
 
 type HostInfo struct {
@@ -74,6 +107,15 @@ type HostInfo struct {
 	Ports   string
 	Url     string
 	Infostr []string
+	Ctx     context.Context // -plugin-timeout 时由 ScanFunc 设置,超时后取消
+}
+
+// 插件运行的context,未设置 -plugin-timeout 时不会被取消
+func (info *HostInfo) Context() context.Context {
+	if info.Ctx == nil {
+		return context.Background()
+	}
+	return info.Ctx
 }
 
 type PocInfo struct {
@@ -124,6 +166,91 @@ var (
 	HostPort    []string
 	IsWmi       bool
 	Noredistest bool
+
+	CertExpiryWarn     string
+	CertExpiryWarnTime time.Duration
+	MaxOpenPorts       int
+	Resolve            string
+	ProxyTarget        string
+	Tags               string
+	FilterTag          string
+	Window             string
+	BannerBytes        int
+	PwdSort            bool
+	SipExt             string
+	DiscoveryRate      int
+	DiscoveryThreads   int
+	BruteRate          int
+	BruteThreads       int
+	InventoryFile      string
+	HostCreds          string
+	HostCredsOnly      bool
+	SamplePerSubnet    int
+	MaxRedirect        int
+	ExportScope        string
+	AttemptTimeout     int64
+	PluginTimeout      int64
+	Group              string
+	BasicAuth          bool
+	Explain            bool
+	PortMap            string
+	PortPlugins        = make(map[string]string)
+	Format             string
+	MaxPortsPerHost    int
+	HostFileHeader     string
+	HostFileCache      string
+	DropRangeHoneypot  bool
+	EngagementID       string
+	NoAuthNotice       bool
+	LowMemory          bool
+	DedupFP            float64
+	Rotate             string
+	RotateN            int
+	RotateM            int
+	TimePoc            bool
+	RedactCreds        bool
+	IP8Fixed           string
+	IP8Bands           string
+	SampleOctets       string
+	SampleRate         int
+	SkipCDN            bool
+	ProbeFile          string
+	ScopeFile          string
+	SnmpCommunity      string
+	OutputDir          string
+	TcpPing            string
+	Jitter             string
+	WebProto           bool
+	RandSeed           int64
+	OpenOnlyOutput     string
+	OOBListen          string
+	OOBHost            string
+	DnsResolve         bool
+	DnsFail            string
+	ConnectRetry       int64
+	FPCache            string
+	FPMaxAge           string
+	RefreshFP          bool
+	HoneypotRun        int
+	ExcludePorts       string
+	ResumeFile         string
+	ResumeMaxAge       string
+	IsDryRun           bool
+	FullScan           bool
+	TargetsJson        string
+	PTRLookup          bool
+	PTRTimeout         int64
+	PTRThreads         int
+	RateLimit          int
+	AllowScope         string
+	AllowScopeAbort    bool
+	ValidateOnly       bool
+	LiveCIDRs          string
+	SampleLarge        bool
+	SkipNetBroadcast   bool
+	PrivateShorthand   bool
+	GroupPlugins       = make(map[string][]string)
+	ResolveMap         = make(map[string]string)
 )
 
 var (
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 口令爆破统计,按协议汇总测试主机数、弱口令主机数和命中最多的密码
+type credStat struct {
+	tested    map[string]struct{}
+	weak      map[string]struct{}
+	passwords map[string]int
+}
+
+var (
+	credStats = make(map[string]*credStat)
+	credMutex sync.Mutex
+)
+
+func getCredStat(protocol string) *credStat {
+	stat, ok := credStats[protocol]
+	if !ok {
+		stat = &credStat{tested: make(map[string]struct{}), weak: make(map[string]struct{}), passwords: make(map[string]int)}
+		credStats[protocol] = stat
+	}
+	return stat
+}
+
+func RecordCredTest(protocol, host string) {
+	credMutex.Lock()
+	getCredStat(protocol).tested[host] = struct{}{}
+	credMutex.Unlock()
+}
+
+func RecordCred(protocol, host, pass string) {
+	credMutex.Lock()
+	stat := getCredStat(protocol)
+	stat.tested[host] = struct{}{}
+	stat.weak[host] = struct{}{}
+	stat.passwords[pass]++
+	credMutex.Unlock()
+}
+
+// -redact-creds 时只保留首字符和长度
+func redactPass(pass string) string {
+	if !RedactCreds {
+		return pass
+	}
+	if pass == "" {
+		return "<empty>"
+	}
+	return fmt.Sprintf("%c***(%d)", []rune(pass)[0], len([]rune(pass)))
+}
+
+// 扫描结束时输出口令统计,同时写入结果文件/json
+func CredAnalytics() {
+	credMutex.Lock()
+	defer credMutex.Unlock()
+	if len(credStats) == 0 {
+		return
+	}
+	var protocols []string
+	total := make(map[string]int)
+	for protocol, stat := range credStats {
+		protocols = append(protocols, protocol)
+		for pass, count := range stat.passwords {
+			total[pass] += count
+		}
+	}
+	sort.Strings(protocols)
+	LogSuccess("[*] CredAnalytics")
+	for _, protocol := range protocols {
+		stat := credStats[protocol]
+		rate := float64(len(stat.weak)) * 100 / float64(len(stat.tested))
+		LogSuccess(fmt.Sprintf("   [->] %-8v tested:%d weak:%d rate:%.1f%%", protocol, len(stat.tested), len(stat.weak), rate))
+	}
+	var passwords []string
+	for pass := range total {
+		passwords = append(passwords, pass)
+	}
+	sort.Slice(passwords, func(i, j int) bool {
+		if total[passwords[i]] != total[passwords[j]] {
+			return total[passwords[i]] > total[passwords[j]]
+		}
+		return passwords[i] < passwords[j]
+	})
+	if len(passwords) > 10 {
+		passwords = passwords[:10]
+	}
+	var top []string
+	for _, pass := range passwords {
+		top = append(top, fmt.Sprintf("%s(%d)", redactPass(pass), total[pass]))
+	}
+	if len(top) > 0 {
+		LogSuccess("   [->] top passwords: " + strings.Join(top, " "))
+	}
+}
@@ -0,0 +1,75 @@
+package common
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync"
+)
+
+// 结果去重。默认用map精确去重,内存随结果数增长;
+// -low-memory 时改用固定大小的布隆过滤器,内存有上限,
+// 代价是有 -dedup-fp 的概率把一条新结果误判为重复而丢掉
+var (
+	dedupSeen  = make(map[string]struct{})
+	dedupBloom *bloomFilter
+	dedupMutex sync.Mutex
+)
+
+// 布隆过滤器按1000万条结果预估容量
+const bloomCapacity = 10000000
+
+type bloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    uint64
+}
+
+func newBloomFilter(n int, p float64) *bloomFilter {
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &bloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+// 双重哈希生成k个位置,全部已置位则认为出现过,同时置位
+func (b *bloomFilter) testAndAdd(s string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	h1 := h.Sum64()
+	h = fnv.New64()
+	h.Write([]byte(s))
+	h2 := h.Sum64() | 1
+	exist := true
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		if b.bits[pos/64]&(1<<(pos%64)) == 0 {
+			exist = false
+			b.bits[pos/64] |= 1 << (pos % 64)
+		}
+	}
+	return exist
+}
+
+// 只对结果行去重,[->] 这类附属行不处理
+func Duplicated(result string) bool {
+	if !strings.HasPrefix(result, "[+]") && !strings.HasPrefix(result, "[*]") && !strings.HasSuffix(result, " open") {
+		return false
+	}
+	dedupMutex.Lock()
+	defer dedupMutex.Unlock()
+	if LowMemory {
+		if dedupBloom == nil {
+			fp := DedupFP
+			if fp <= 0 || fp >= 1 {
+				fp = 0.001
+			}
+			dedupBloom = newBloomFilter(bloomCapacity, fp)
+		}
+		return dedupBloom.testAndAdd(result)
+	}
+	if _, ok := dedupSeen[result]; ok {
+		return true
+	}
+	dedupSeen[result] = struct{}{}
+	return false
+}
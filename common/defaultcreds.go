@@ -0,0 +1,102 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 厂商/产品默认口令库,按指纹(banner、web指纹、basic认证realm等)匹配,
+// 命中的产品先试专属默认口令再跑通用字典,新增产品直接往 ProductCreds 里加
+type ProductCred struct {
+	Product string
+	Match   *regexp.Regexp
+	Creds   []Cred
+}
+
+var ProductCreds = []ProductCred{
+	{"MikroTik RouterOS", regexp.MustCompile(`(?i)ROSSSH|MikroTik`), []Cred{{"admin", ""}}},
+	{"Huawei", regexp.MustCompile(`(?i)HUAWEI|VRP`), []Cred{{"admin", "Admin@123"}, {"admin", "Huawei@123"}, {"root", "Huawei@123"}, {"admin", "admin@huawei.com"}}},
+	{"H3C", regexp.MustCompile(`(?i)H3C|Comware`), []Cred{{"admin", "admin"}, {"h3c", "h3capadmin"}, {"admin", "h3capadmin"}}},
+	{"Ruijie", regexp.MustCompile(`(?i)Ruijie`), []Cred{{"admin", "admin"}, {"admin", "ruijie"}}},
+	{"ZTE", regexp.MustCompile(`(?i)ZTE`), []Cred{{"admin", "admin"}, {"telecomadmin", "nE7jA%5m"}, {"CMCCAdmin", "aDm8H%MdA"}}},
+	{"Hikvision", regexp.MustCompile(`(?i)Hikvision|DNVRS-Webs|App-webs`), []Cred{{"admin", "12345"}, {"admin", "888888"}}},
+	{"Dahua", regexp.MustCompile(`(?i)Dahua|DH-`), []Cred{{"admin", "admin"}, {"888888", "888888"}, {"666666", "666666"}}},
+	{"Cisco", regexp.MustCompile(`(?i)Cisco`), []Cred{{"cisco", "cisco"}, {"admin", "cisco"}}},
+	{"TP-Link", regexp.MustCompile(`(?i)TP-LINK`), []Cred{{"admin", "admin"}}},
+	{"Ubiquiti", regexp.MustCompile(`(?i)UBNT|Ubiquiti|dropbear_0\.5`), []Cred{{"ubnt", "ubnt"}}},
+	{"Raspberry Pi", regexp.MustCompile(`(?i)Raspbian`), []Cred{{"pi", "raspberry"}}},
+	{"Tomcat", regexp.MustCompile(`(?i)Tomcat`), []Cred{{"tomcat", "tomcat"}, {"admin", "admin"}, {"tomcat", "s3cret"}}},
+}
+
+var (
+	fingerprints     = make(map[string]string)
+	fingerprintMutex sync.RWMutex
+)
+
+// 记录 host:port 的指纹文本,供默认口令匹配
+func SetFingerprint(host, port, text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	key := host + ":" + port
+	fingerprintMutex.Lock()
+	if !fpCached[key] || !strings.Contains(fingerprints[key], text) {
+		fingerprints[key] += " " + text
+	}
+	cacheFingerprint(key, text)
+	fingerprintMutex.Unlock()
+}
+
+func Fingerprint(host, port string) string {
+	fingerprintMutex.RLock()
+	defer fingerprintMutex.RUnlock()
+	return fingerprints[host+":"+port]
+}
+
+// 主动发banner的协议没有指纹时先读一次banner
+func grabBanner(host, port string) string {
+	conn, err := WrapperTcpWithTimeout("tcp", host+":"+port, time.Duration(Timeout)*time.Second)
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Duration(Timeout) * time.Second))
+	buf := make([]byte, 256)
+	n, _ := conn.Read(buf)
+	return string(buf[:n])
+}
+
+func MatchProduct(fingerprint string) []ProductCred {
+	var products []ProductCred
+	for _, product := range ProductCreds {
+		if product.Match.MatchString(fingerprint) {
+			products = append(products, product)
+		}
+	}
+	return products
+}
+
+// 按指纹尝试产品默认口令,返回true表示已成功
+func TryProductCreds(host, port, protocol string, try func(user, pass string) (bool, error)) bool {
+	fingerprint := Fingerprint(host, port)
+	if fingerprint == "" && (protocol == "ssh" || protocol == "ftp") {
+		fingerprint = grabBanner(host, port)
+		SetFingerprint(host, port, fingerprint)
+	}
+	for _, product := range MatchProduct(fingerprint) {
+		for _, cred := range product.Creds {
+			BruteLimiter.Wait()
+			flag, err := try(cred.User, cred.Pass)
+			if flag && err == nil {
+				LogSuccess(fmt.Sprintf("[+] DefaultCred %v:%v %v product:%v %v %v", host, port, protocol, product.Product, cred.User, cred.Pass))
+				return true
+			}
+			errlog := fmt.Sprintf("[-] %v %v:%v %v default %v %v %v", protocol, host, port, product.Product, cred.User, cred.Pass, err)
+			LogError(errlog)
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+// 启动时提醒确认授权,并把 -engagement 授权编号写入结果文件,方便事后追溯
+func Engagement() {
+	if !NoAuthNotice {
+		fmt.Println("[*] 请确认已获得目标的书面授权,仅用于授权范围内的测试 (-no-auth-notice 关闭此提示)")
+	}
+	if OutputDir != "" {
+		LogSuccess(fmt.Sprintf("[*] Run %s start:%s", RunID, time.Now().Format("2006-01-02 15:04:05")))
+	}
+	if EngagementID == "" {
+		return
+	}
+	LogSuccess(fmt.Sprintf("[*] Engagement %s start:%s", EngagementID, time.Now().Format("2006-01-02 15:04:05")))
+}
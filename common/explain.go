@@ -0,0 +1,57 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// -explain 诊断模式,逐个host:port输出没有结果的原因,只打印不写入结果文件
+var (
+	findingCount = make(map[string]int)
+	findingMutex sync.Mutex
+	hostPortReg  = regexp.MustCompile(`\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}(:\d{1,5})?`)
+)
+
+func ExplainLog(target string, reason string) {
+	if Explain {
+		fmt.Printf("[debug] %s %s\n", target, reason)
+	}
+}
+
+// 记录结果中出现的 host 和 host:port,用于判断插件是否有发现
+func countFinding(result string) {
+	if !Explain || !strings.HasPrefix(result, "[+]") {
+		return
+	}
+	findingMutex.Lock()
+	defer findingMutex.Unlock()
+	for _, find := range hostPortReg.FindAllString(result, -1) {
+		findingCount[find]++
+		if index := strings.Index(find, ":"); index != -1 {
+			findingCount[find[:index]]++
+		}
+	}
+}
+
+// 返回host:port和host的发现次数,插件执行前后对比即可知道是否有新结果
+func FindingCount(host, port string) int {
+	findingMutex.Lock()
+	defer findingMutex.Unlock()
+	return findingCount[host+":"+port] + findingCount[host]
+}
+
+func ExplainErr(err error) string {
+	if err == nil {
+		return "no error"
+	}
+	text := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(text, "refused"):
+		return "connection refused"
+	case strings.Contains(text, "timeout"):
+		return "timeout"
+	}
+	return err.Error()
+}
@@ -18,54 +18,138 @@ func Banner() {
 
 func Flag(Info *HostInfo) {
 	Banner()
-	flag.StringVar(&Info.Host, "h", "", "IP address of the host you want to scan,for example: 192.168.11.11 | 192.168.11.11-255 | 192.168.11.11,192.168.11.12")
-	flag.StringVar(&NoHosts, "hn", "", "the hosts no scan,as: -hn 192.168.1.1/24")
-	flag.StringVar(&Ports, "p", DefaultPorts, "Select a port,for example: 22 | 1-65535 | 22,80,3306")
-	flag.StringVar(&PortAdd, "pa", "", "add port base DefaultPorts,-pa 3389")
-	flag.StringVar(&UserAdd, "usera", "", "add a user base DefaultUsers,-usera user")
-	flag.StringVar(&PassAdd, "pwda", "", "add a password base DefaultPasses,-pwda password")
-	flag.StringVar(&NoPorts, "pn", "", "the ports no scan,as: -pn 445")
-	flag.StringVar(&Command, "c", "", "exec command (ssh|wmiexec)")
-	flag.StringVar(&SshKey, "sshkey", "", "sshkey file (id_rsa)")
-	flag.StringVar(&Domain, "domain", "", "smb domain")
-	flag.StringVar(&Username, "user", "", "username")
-	flag.StringVar(&Password, "pwd", "", "password")
-	flag.Int64Var(&Timeout, "time", 3, "Set timeout")
-	flag.StringVar(&Scantype, "m", "all", "Select scan type ,as: -m ssh")
-	flag.StringVar(&Path, "path", "", "fcgi、smb romote file path")
-	flag.IntVar(&Threads, "t", 600, "Thread nums")
-	flag.IntVar(&LiveTop, "top", 10, "show live len top")
-	flag.StringVar(&HostFile, "hf", "", "host file, -hf ip.txt")
-	flag.StringVar(&Userfile, "userf", "", "username file")
-	flag.StringVar(&Passfile, "pwdf", "", "password file")
-	flag.StringVar(&PortFile, "portf", "", "Port File")
-	flag.StringVar(&PocPath, "pocpath", "", "poc file path")
-	flag.StringVar(&RedisFile, "rf", "", "redis file to write sshkey file (as: -rf id_rsa.pub)")
-	flag.StringVar(&RedisShell, "rs", "", "redis shell to write cron file (as: -rs 192.168.1.1:6666)")
-	flag.BoolVar(&NoPoc, "nopoc", false, "not to scan web vul")
-	flag.BoolVar(&IsBrute, "nobr", false, "not to Brute password")
-	flag.IntVar(&BruteThread, "br", 1, "Brute threads")
-	flag.BoolVar(&NoPing, "np", false, "not to ping")
-	flag.BoolVar(&Ping, "ping", false, "using ping replace icmp")
-	flag.StringVar(&Outputfile, "o", "result.txt", "Outputfile")
-	flag.BoolVar(&TmpSave, "no", false, "not to save output log")
-	flag.Int64Var(&WaitTime, "debug", 60, "every time to LogErr")
-	flag.BoolVar(&Silent, "silent", false, "silent scan")
-	flag.BoolVar(&Nocolor, "nocolor", false, "no color")
-	flag.BoolVar(&PocFull, "full", false, "poc full scan,as: shiro 100 key")
-	flag.StringVar(&URL, "u", "", "url")
-	flag.StringVar(&UrlFile, "uf", "", "urlfile")
-	flag.StringVar(&Pocinfo.PocName, "pocname", "", "use the pocs these contain pocname, -pocname weblogic")
-	flag.StringVar(&Proxy, "proxy", "", "set poc proxy, -proxy http://127.0.0.1:8080")
-	flag.StringVar(&Socks5Proxy, "socks5", "", "set socks5 proxy, will be used in tcp connection, timeout setting will not work")
-	flag.StringVar(&Cookie, "cookie", "", "set poc cookie,-cookie rememberMe=login")
-	flag.Int64Var(&WebTimeout, "wt", 5, "Set web timeout")
-	flag.BoolVar(&DnsLog, "dns", false, "using dnslog poc")
-	flag.IntVar(&PocNum, "num", 20, "poc rate")
-	flag.StringVar(&SC, "sc", "", "ms17 shellcode,as -sc add")
-	flag.BoolVar(&IsWmi, "wmi", false, "start wmi")
-	flag.StringVar(&Hash, "hash", "", "hash")
-	flag.BoolVar(&Noredistest, "noredis", false, "no redis sec test")
-	flag.BoolVar(&JsonOutput, "json", false, "json output")
+	defineFlags(flag.CommandLine, Info)
 	flag.Parse()
 }
+
+// 定义全部参数,绑定的变量同时被设为默认值;-scopes 的每个范围都在新的 FlagSet 上重新定义一次
+func defineFlags(fs *flag.FlagSet, Info *HostInfo) {
+	fs.StringVar(&Info.Host, "h", "", "IP address of the host you want to scan,for example: 192.168.11.11 | 192.168.11.11-255 | 192.168.11.11,192.168.11.12")
+	fs.StringVar(&NoHosts, "hn", "", "the hosts no scan,as: -hn 192.168.1.1/24")
+	fs.StringVar(&Ports, "p", DefaultPorts, "Select a port,for example: 22 | 1-65535 | 22,80,3306")
+	fs.StringVar(&PortAdd, "pa", "", "add port base DefaultPorts,-pa 3389")
+	fs.StringVar(&UserAdd, "usera", "", "add a user base DefaultUsers,-usera user")
+	fs.StringVar(&PassAdd, "pwda", "", "add a password base DefaultPasses,-pwda password")
+	fs.StringVar(&NoPorts, "pn", "", "the ports no scan,as: -pn 445")
+	fs.StringVar(&Command, "c", "", "exec command (ssh|wmiexec)")
+	fs.StringVar(&SshKey, "sshkey", "", "sshkey file (id_rsa)")
+	fs.StringVar(&Domain, "domain", "", "smb domain")
+	fs.StringVar(&Username, "user", "", "username")
+	fs.StringVar(&Password, "pwd", "", "password")
+	fs.Int64Var(&Timeout, "time", 3, "Set timeout")
+	fs.StringVar(&Scantype, "m", "all", "Select scan type ,as: -m ssh")
+	fs.StringVar(&Path, "path", "", "fcgi、smb romote file path")
+	fs.IntVar(&Threads, "t", 600, "Thread nums")
+	fs.IntVar(&LiveTop, "top", 10, "show live len top")
+	fs.StringVar(&HostFile, "hf", "", "host file, -hf ip.txt, - reads stdin")
+	fs.StringVar(&Userfile, "userf", "", "username file")
+	fs.StringVar(&Passfile, "pwdf", "", "password file")
+	fs.StringVar(&PortFile, "portf", "", "Port File")
+	fs.StringVar(&PocPath, "pocpath", "", "poc file path")
+	fs.StringVar(&RedisFile, "rf", "", "redis file to write sshkey file (as: -rf id_rsa.pub)")
+	fs.StringVar(&RedisShell, "rs", "", "redis shell to write cron file (as: -rs 192.168.1.1:6666)")
+	fs.BoolVar(&NoPoc, "nopoc", false, "not to scan web vul")
+	fs.BoolVar(&IsBrute, "nobr", false, "not to Brute password")
+	fs.IntVar(&BruteThread, "br", 1, "Brute threads")
+	fs.BoolVar(&NoPing, "np", false, "not to ping")
+	fs.BoolVar(&Ping, "ping", false, "using ping replace icmp")
+	fs.StringVar(&Outputfile, "o", "result.txt", "Outputfile")
+	fs.BoolVar(&TmpSave, "no", false, "not to save output log")
+	fs.Int64Var(&WaitTime, "debug", 60, "every time to LogErr")
+	fs.BoolVar(&Silent, "silent", false, "silent scan")
+	fs.BoolVar(&Nocolor, "nocolor", false, "no color")
+	fs.BoolVar(&PocFull, "full", false, "poc full scan,as: shiro 100 key")
+	fs.StringVar(&URL, "u", "", "url")
+	fs.StringVar(&UrlFile, "uf", "", "urlfile")
+	fs.StringVar(&Pocinfo.PocName, "pocname", "", "use the pocs these contain pocname, -pocname weblogic")
+	fs.StringVar(&Proxy, "proxy", "", "set poc proxy, -proxy http://127.0.0.1:8080")
+	fs.StringVar(&Socks5Proxy, "socks5", "", "set socks5 proxy, will be used in tcp connection, timeout setting will not work")
+	fs.StringVar(&Cookie, "cookie", "", "set poc cookie,-cookie rememberMe=login")
+	fs.Int64Var(&WebTimeout, "wt", 5, "Set web timeout")
+	fs.BoolVar(&DnsLog, "dns", false, "using dnslog poc")
+	fs.IntVar(&PocNum, "num", 20, "poc rate")
+	fs.StringVar(&SC, "sc", "", "ms17 shellcode,as -sc add")
+	fs.BoolVar(&IsWmi, "wmi", false, "start wmi")
+	fs.StringVar(&Hash, "hash", "", "hash")
+	fs.BoolVar(&Noredistest, "noredis", false, "no redis sec test")
+	fs.BoolVar(&JsonOutput, "json", false, "json output")
+	fs.StringVar(&CertExpiryWarn, "cert-expiry-warn", "30d", "warn when tls cert expires within this window, as: -cert-expiry-warn 30d")
+	fs.IntVar(&MaxOpenPorts, "max-open-ports", 0, "mark host as tarpit and skip it when open ports exceed this, as: -max-open-ports 1000")
+	fs.StringVar(&ProxyTarget, "proxy-target", "", "safe destination used to test open proxy relay, as: host:80, the open proxy check is skipped if empty")
+	fs.StringVar(&Tags, "tag", "", "tag groups of targets, as: -tag \"prod=10.1.0.0/16;lab=10.99.0.0/16\"")
+	fs.StringVar(&FilterTag, "filter-tag", "", "only output results of targets with this tag, as: -filter-tag prod")
+	fs.StringVar(&Window, "window", "", "only dispatch scans within this time window, as: -window \"Mon-Fri 22:00-04:00\"")
+	fs.IntVar(&BannerBytes, "banner-bytes", 32, "hex dump first n banner bytes of unknown services, 0 to disable")
+	fs.BoolVar(&PwdSort, "pwd-sort", false, "try the most common passwords first")
+	fs.StringVar(&SipExt, "sip-ext", "", "enumerate sip extensions over udp or tcp, 5/s unless -brute-rate, as: -sip-ext 100-199,8000")
+	fs.IntVar(&DiscoveryRate, "discovery-rate", 0, "max port scan connects per second, 0 is unlimited")
+	fs.IntVar(&RateLimit, "rate", 0, "max new hosts handed to the scanner per second, 0 is unlimited")
+	fs.IntVar(&DiscoveryThreads, "discovery-threads", 0, "port scan threads, default same as -t")
+	fs.IntVar(&BruteRate, "brute-rate", 0, "max brute attempts per second, 0 is unlimited")
+	fs.IntVar(&BruteThreads, "brute-threads", 0, "max concurrent brute tasks, 0 is unlimited (still limited by -t)")
+	fs.StringVar(&InventoryFile, "inventory", "inventory.json", "asset inventory output file of -m inventory")
+	fs.StringVar(&HostCreds, "host-creds", "", "per host credentials file, line as: host,protocol,user,pass")
+	fs.BoolVar(&HostCredsOnly, "host-creds-only", false, "only try -host-creds credentials for the hosts listed in it")
+	fs.IntVar(&SamplePerSubnet, "sample-per-subnet", 0, "keep at most n random hosts per /24, as: -sample-per-subnet 2")
+	fs.IntVar(&MaxRedirect, "max-redirect", 10, "max redirects to follow, redirects to out-of-scope hosts are not followed")
+	fs.StringVar(&LiveCIDRs, "live-cidrs", "", "after scanning, write live hosts as minimal cidrs, as: -live-cidrs live.txt")
+	fs.StringVar(&ExportScope, "export-scope", "", "export the final target set as compact cidrs, as: -export-scope scope-out.txt")
+	fs.Int64Var(&AttemptTimeout, "attempt-timeout", 20, "max seconds of one connect+handshake+auth attempt, caps every later deadline on the conn and db driver timeouts, 0 is off")
+	fs.Int64Var(&PluginTimeout, "plugin-timeout", 0, "max seconds of one plugin run on a target, 0 is unlimited")
+	fs.StringVar(&Group, "group", "", "scan a service group, selects both ports and plugins: db|web|remote|mail|infra")
+	fs.BoolVar(&BasicAuth, "basic-auth", false, "try a few default creds on basic-auth protected pages, rate limited by -brute-rate")
+	fs.BoolVar(&Explain, "explain", false, "print debug traces of why each host:port produced no finding")
+	fs.StringVar(&PortMap, "port-map", "", "run a plugin on a non-standard port, as: -port-map 2222=ssh,13306=mysql")
+	fs.StringVar(&Format, "format", "txt", "output format: txt|dot|jsonl|sarif, dot/sarif write a graphviz graph or SARIF 2.1.0 report at the end, jsonl with -o - streams to stdout")
+	fs.IntVar(&MaxPortsPerHost, "max-ports-per-host", 0, "probe at most n ports per host, common service ports first")
+	fs.StringVar(&HostFileHeader, "hf-header", "", "headers used to fetch -hf http(s) url, as: -hf-header \"Authorization: Bearer xxx;X-Token: xxx\"")
+	fs.StringVar(&HostFileCache, "hf-cache", "", "cache file of -hf http(s) url, reused by etag when not modified")
+	fs.BoolVar(&DropRangeHoneypot, "drop-range-honeypot", false, "drop open ports of /24 ranges that look like one honeypot/firewall answering for all hosts")
+	fs.StringVar(&EngagementID, "engagement", "", "authorization/engagement reference written into the outputs, as: -engagement RoE-2024-017")
+	fs.BoolVar(&NoAuthNotice, "no-auth-notice", false, "do not print the authorization reminder at startup")
+	fs.BoolVar(&LowMemory, "low-memory", false, "dedup results with a bloom filter of bounded memory instead of an exact map, may drop a few new results")
+	fs.Float64Var(&DedupFP, "dedup-fp", 0.001, "false positive rate of the -low-memory bloom filter")
+	fs.StringVar(&Rotate, "rotate", "", "scan slice N of M stable partitions of the targets, as: -rotate 1-of-7")
+	fs.BoolVar(&TimePoc, "time-poc", false, "enable pocs with time-based matcher, they send benign sleep payloads and compare response time")
+	fs.BoolVar(&RedactCreds, "redact-creds", false, "redact passwords in the credential analytics summary")
+	fs.StringVar(&IP8Fixed, "ip8-fixed", "", "last octets always scanned in each /24 of a /8, e.g. 1,2,10,100,254; each octet adds 65536 targets per /8, default 1,2,4,5,254")
+	fs.StringVar(&IP8Bands, "ip8-bands", "", "random samples per last-octet band in each /24 of a /8, or N random picks in 1-254 (0 disables), default 6-55:1,56-100:1,101-150:1,151-200:1,201-253:1")
+	fs.BoolVar(&PrivateShorthand, "private-shorthand", false, "treat bare 192/172/10 as 192.168.0.0/16, 172.16.0.0/12, 10.0.0.0/8 (same as private:10)")
+	fs.BoolVar(&SkipNetBroadcast, "skip-network-broadcast", false, "drop the network and broadcast address of each cidr (/30 and larger)")
+	fs.BoolVar(&SampleLarge, "sample", false, "also sample each /24 of /9-/23 ranges (e.g. /16, /12) like /8 instead of full expansion")
+	fs.StringVar(&SampleOctets, "sample-octets", "", "last octets probed in each /24 of a /8, replaces the gateway set and turns off random picks, e.g. 1,2,10,100,254; each octet adds 65536 targets per /8")
+	fs.IntVar(&SampleRate, "sample-rate", -1, "random last octets picked in each /24 of a /8 (0 disables), more picks find more hosts but scan slower")
+	fs.BoolVar(&SkipCDN, "skip-cdn", false, "skip targets in known CDN/WAF ip ranges")
+	fs.StringVar(&ProbeFile, "probe-file", "", "custom probe payloads per port, line format: name|ports|hex:xx or str:xx|match regex")
+	fs.StringVar(&ScopeFile, "scopes", "", "run several isolated scopes one after another, one per line as: name: -h 10.0.0.0/24 -p 22,80")
+	fs.StringVar(&SnmpCommunity, "snmp-community", "public,private,manager,community,cisco,admin", "snmp communities to try with -m snmp, writable ones are checked by writing back the current sysContact")
+	fs.StringVar(&OutputDir, "output-dir", "", "write all artifacts of this run into <output-dir>/<time>-<run id>/, as: -output-dir ./scans")
+	fs.StringVar(&TcpPing, "tcp-ping", "", "skip hosts answering on none of these tcp ports before the scan, as: -tcp-ping 80,443,22,445")
+	fs.StringVar(&Jitter, "jitter", "", "random delay between two connections to the same host, as: -jitter 100-500ms")
+	fs.BoolVar(&WebProto, "web-proto", false, "detect websocket and http/2 (alpn h2, h2c upgrade) support of web services")
+	fs.Int64Var(&RandSeed, "rand-seed", 0, "seed for random target sampling (/8, -sample-per-subnet), same seed gives the same host list")
+	fs.StringVar(&OpenOnlyOutput, "open-only-output", "", "also write every open port as one host:port per line, as: -open-only-output open.txt")
+	fs.StringVar(&OOBListen, "oob-listen", "", "enable oob pocs (log4shell) with a built-in ldap callback listener, as: -oob-listen 0.0.0.0:1389")
+	fs.StringVar(&OOBHost, "oob-host", "", "callback address put into oob payloads, reachable by targets, default -oob-listen, as: -oob-host 1.2.3.4:1389")
+	fs.BoolVar(&DnsResolve, "dns-resolve", false, "resolve hostname targets to all their A/AAAA records, results are marked with [host:name]")
+	fs.StringVar(&DnsFail, "dns-fail", "keep", "when -dns-resolve fails: keep (scan the hostname as is) | skip")
+	fs.Int64Var(&ConnectRetry, "connect-retry", 0, "retry a timed out tcp connect once with this timeout (seconds), default 0 (off), as: -time 1 -connect-retry 5")
+	fs.StringVar(&FPCache, "fp-cache", "", "load/save service fingerprints, cached host:port skip re-fingerprinting, as: -fp-cache fp.json")
+	fs.StringVar(&FPMaxAge, "fp-max-age", "7d", "cached fingerprints older than this are refreshed")
+	fs.BoolVar(&RefreshFP, "refresh-fingerprints", false, "ignore -fp-cache entries and fingerprint again")
+	fs.IntVar(&HoneypotRun, "honeypot-run", 50, "flag hosts with at least this many contiguous open ports as likely honeypots, 0 to disable")
+	fs.StringVar(&ExcludePorts, "exclude-ports", "", "ports never scanned, same syntax as -p incl. presets, as: -exclude-ports 3389,445")
+	fs.StringVar(&ResumeFile, "resume-file", "", "host state cache (json lines), hosts found dead recently are skipped on the next run, as: -resume-file state.jsonl")
+	fs.StringVar(&ResumeMaxAge, "resume-max-age", "24h", "how long a dead host in -resume-file stays skipped")
+	fs.StringVar(&Lang, "lang", "zh", "language of parse messages, zh or en")
+	fs.BoolVar(&PTRLookup, "ptr", false, "reverse lookup PTR records of parsed hosts")
+	fs.Int64Var(&PTRTimeout, "ptr-timeout", 2, "timeout in seconds of each PTR lookup")
+	fs.IntVar(&PTRThreads, "ptr-threads", 50, "concurrent PTR lookups")
+	fs.StringVar(&AllowScope, "allow-scope", "", "file of authorized cidrs, targets outside are dropped")
+	fs.BoolVar(&AllowScopeAbort, "allow-scope-abort", false, "abort instead of dropping when any target is outside -allow-scope")
+	fs.StringVar(&TargetsJson, "targets-json", "", "write parsed targets as json [{ip, port, source}], - for stdout")
+	fs.BoolVar(&FullScan, "ip8-full", false, "fully expand /8 ranges instead of sampling, 16M hosts per /8")
+	fs.BoolVar(&ValidateOnly, "validate", false, "only check -h/-hf targets, list every invalid entry and exit 1 if any")
+	fs.BoolVar(&IsDryRun, "dry-run", false, "only print the estimated number of targets and exit")
+	fs.StringVar(&Resolve, "resolve", "", "force host to connect to ip, as: -resolve app.example.com:10.0.0.5,b.example.com:10.0.0.6")
+}
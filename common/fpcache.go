@@ -0,0 +1,103 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// -fp-cache 指纹缓存: 上次运行结束时写入 host:port 的指纹和时间,下次运行时载入,
+// 未超过 -fp-max-age 的指纹直接使用,跳过banner抓取/服务探测,直接进入口令和漏洞检测;
+// -refresh-fingerprints 忽略缓存重新识别(仍会写回缓存)
+type fpCacheEntry struct {
+	Fingerprint string    `json:"fingerprint"`
+	Time        time.Time `json:"time"`
+}
+
+var (
+	fpCache    = make(map[string]fpCacheEntry)
+	fpCached   = make(map[string]bool)
+	fpMaxAge   time.Duration
+	fpCacheNew bool
+)
+
+func LoadFingerprintCache() {
+	if FPCache == "" {
+		return
+	}
+	var err error
+	if fpMaxAge, err = ParseDuration(FPMaxAge); err != nil {
+		fmt.Println("[-] fp-max-age parse error:", err)
+		os.Exit(0)
+	}
+	data, err := os.ReadFile(FPCache)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &fpCache)
+	}
+	if err != nil {
+		fmt.Println("[-] fp-cache parse error:", err)
+		os.Exit(0)
+	}
+	if RefreshFP {
+		return
+	}
+	var fresh, stale int
+	fingerprintMutex.Lock()
+	for key, entry := range fpCache {
+		if time.Since(entry.Time) > fpMaxAge {
+			stale++
+			continue
+		}
+		fingerprints[key] = entry.Fingerprint
+		fpCached[key] = true
+		fresh++
+	}
+	fingerprintMutex.Unlock()
+	fmt.Printf("[*] fp-cache loaded %d fingerprints, %d stale ones will be refreshed\n", fresh, stale)
+}
+
+// host:port 是否有可用的缓存指纹
+func FingerprintCached(host, port string) bool {
+	fingerprintMutex.RLock()
+	defer fingerprintMutex.RUnlock()
+	return fpCached[host+":"+port]
+}
+
+// 记录新识别的指纹,调用方已持有 fingerprintMutex
+func cacheFingerprint(key, text string) {
+	if FPCache == "" || fpCached[key] {
+		return
+	}
+	entry := fpCache[key]
+	if entry.Time.Before(startTime) {
+		entry.Fingerprint = ""
+	}
+	if !strings.Contains(entry.Fingerprint, text) {
+		entry.Fingerprint = strings.TrimSpace(entry.Fingerprint + " " + text)
+	}
+	entry.Time = time.Now()
+	fpCache[key] = entry
+	fpCacheNew = true
+}
+
+var startTime = time.Now()
+
+func SaveFingerprintCache() {
+	if FPCache == "" || !fpCacheNew {
+		return
+	}
+	fingerprintMutex.RLock()
+	data, err := json.MarshalIndent(fpCache, "", "  ")
+	fingerprintMutex.RUnlock()
+	if err == nil {
+		err = os.WriteFile(FPCache, data, 0666)
+	}
+	if err != nil {
+		fmt.Printf("[-] Write %s error, %v\n", FPCache, err)
+	}
+}
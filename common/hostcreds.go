@@ -0,0 +1,68 @@
+package common
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type Cred struct {
+	User string
+	Pass string
+}
+
+// host|protocol -> 指定凭据
+var HostCredMap = make(map[string][]Cred)
+
+// 读取 host,protocol,user,pass 格式的凭据映射文件,host支持ip段
+func ParseHostCreds(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) != 4 {
+			fmt.Println("[-] host-creds skip invalid line:", strings.Join(record, ","))
+			continue
+		}
+		protocol := strings.ToLower(strings.TrimSpace(record[1]))
+		cred := Cred{User: strings.TrimSpace(record[2]), Pass: record[3]}
+		for _, host := range ParseIPs(strings.TrimSpace(record[0])) {
+			key := host + "|" + protocol
+			HostCredMap[key] = append(HostCredMap[key], cred)
+		}
+	}
+	return nil
+}
+
+// 先尝试映射文件中该主机的指定凭据,返回true表示已成功,
+// 或者设置了 -host-creds-only 且该主机有指定凭据,无需再跑字典
+func TryHostCreds(host, protocol string, try func(user, pass string) (bool, error)) bool {
+	creds, ok := HostCredMap[host+"|"+protocol]
+	if !ok {
+		return false
+	}
+	for _, cred := range creds {
+		BruteLimiter.Wait()
+		flag, err := try(cred.User, cred.Pass)
+		if flag && err == nil {
+			return true
+		}
+		errlog := fmt.Sprintf("[-] %v %v %v %v %v", protocol, host, cred.User, cred.Pass, err)
+		LogError(errlog)
+	}
+	return HostCredsOnly
+}
@@ -0,0 +1,111 @@
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -resume-file 主机状态缓存,每行一个json,按ip记录上次是否存活和开放端口,多次运行追加写入,
+// 读取时同一ip以最后一行为准。-resume-max-age 内确认不存活的主机再次扫描时直接跳过
+type HostState struct {
+	IP    string    `json:"ip"`
+	Alive bool      `json:"alive"`
+	Ports []int     `json:"ports,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+var hostStates = make(map[string]HostState)
+
+func LoadHostState() {
+	if ResumeFile == "" {
+		return
+	}
+	file, err := os.Open(ResumeFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		fmt.Println("[-] resume-file parse error:", err)
+		os.Exit(0)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var state HostState
+		if err := json.Unmarshal([]byte(text), &state); err != nil || state.IP == "" {
+			fmt.Printf("[!] %s line %d is corrupt, skipped\n", ResumeFile, line)
+			continue
+		}
+		hostStates[state.IP] = state
+	}
+	fmt.Printf("[*] resume-file loaded %d hosts\n", len(hostStates))
+}
+
+// 去掉最近一次运行中确认不存活的主机
+func SkipDeadHosts(hosts []string) []string {
+	if len(hostStates) == 0 {
+		return hosts
+	}
+	maxAge, err := ParseDuration(ResumeMaxAge)
+	if err != nil {
+		fmt.Println("[-] resume-max-age parse error:", err)
+		os.Exit(0)
+	}
+	var result []string
+	for _, host := range hosts {
+		state, ok := hostStates[host]
+		if ok && !state.Alive && time.Since(state.Time) <= maxAge {
+			ExplainLog(host, "skipped, dead in "+ResumeFile+" at "+state.Time.Format("2006-01-02 15:04:05"))
+			continue
+		}
+		result = append(result, host)
+	}
+	if skipped := len(hosts) - len(result); skipped > 0 {
+		fmt.Printf("[*] resume-file: skip %d hosts known dead, %d left\n", skipped, len(result))
+	}
+	return result
+}
+
+// 追加写本次扫描的主机状态,live为存活主机,alivePorts为 host:port 列表
+func SaveHostState(hosts []string, live []string, alivePorts []string) {
+	if ResumeFile == "" || len(hosts) == 0 {
+		return
+	}
+	now := time.Now()
+	alive := make(map[string]bool)
+	for _, host := range live {
+		alive[host] = true
+	}
+	ports := make(map[string][]int)
+	for _, address := range alivePorts {
+		index := strings.LastIndex(address, ":")
+		if port, err := strconv.Atoi(address[index+1:]); err == nil {
+			host := address[:index]
+			ports[host] = append(ports[host], port)
+			alive[host] = true
+		}
+	}
+	var data []byte
+	for _, host := range hosts {
+		state := HostState{IP: host, Alive: alive[host], Ports: ports[host], Time: now}
+		sort.Ints(state.Ports)
+		line, err := json.Marshal(state)
+		if err != nil {
+			continue
+		}
+		data = append(append(data, line...), '\n')
+	}
+	AppendOutput(ResumeFile, data)
+}
@@ -0,0 +1,79 @@
+package common
+
+import "fmt"
+
+// 输出语言,-lang 指定,默认中文
+var Lang = "zh"
+
+// 消息目录:消息id -> 语言 -> 文本,缺少对应语言时回退到中文
+var Messages = map[string]map[string]string{
+	"parse_ip_err": {
+		"zh": " 主机解析错误\n支持的格式: \n",
+		"en": " host parsing error\nformat: \n",
+	},
+	"empty_host_file": {
+		"zh": "主机文件中没有可解析的目标",
+		"en": "host file contains no parseable host",
+	},
+	"exclude_all": {
+		"zh": "排除列表(-hn)覆盖了全部目标,没有可扫描的主机",
+		"en": "the exclude list (-hn) covers the entire target set, no host left to scan",
+	},
+	"ip_range_limit": {
+		"zh": "[-] %s 会展开为 %d 个地址,超过上限 %d (MaxIPRange)",
+		"en": "[-] %s would produce %d addresses, more than the limit %d (MaxIPRange)",
+	},
+	"invalid_ip_range": {
+		"zh": "[-] 无效的IP段 %s (%v), 格式如 192.168.1.1-255 或 192.168.1.1-192.168.1.100",
+		"en": "[-] invalid ip range %s (%v), as: 192.168.1.1-255 or 192.168.1.1-192.168.1.100",
+	},
+	"private_shorthand_off": {
+		"zh": "[-] %s 被当作无效ip跳过;私网简写请写成 private:%[1]s,或加 -private-shorthand",
+		"en": "[-] %s is treated as an invalid ip; for the private range write private:%[1]s or add -private-shorthand",
+	},
+	"private_expand": {
+		"zh": "[!] %s 展开为私网网段 %s",
+		"en": "[!] %s expands to private range %s",
+	},
+	"targets_saved": {
+		"zh": "[*] 目标已保存到 %s, 数量: %d",
+		"en": "[*] targets saved to %s, count: %d",
+	},
+	"ipv6_too_large": {
+		"zh": "[-] ipv6网段过大,最大支持/112: %s",
+		"en": "[-] ipv6 range too large, max /112: %s",
+	},
+	"sample_subnet": {
+		"zh": "[*] 抽样 %s.0/24 保留 %d 丢弃 %d",
+		"en": "[*] sample %s.0/24 keep %d drop %d",
+	},
+	"sample_summary": {
+		"zh": "[*] sample-per-subnet %d: 共 %d 个子网, 丢弃 %d 个主机",
+		"en": "[*] sample-per-subnet %d: %d subnets, drop %d hosts",
+	},
+}
+
+// 按 Lang 取消息并格式化
+func Msg(id string, args ...interface{}) string {
+	texts := Messages[id]
+	text, ok := texts[Lang]
+	if !ok {
+		if text, ok = texts["zh"]; !ok {
+			text = id
+		}
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// 文本在输出时才按 Lang 取,仍可用 == / errors.Is 比较
+type msgError struct {
+	id     string
+	suffix string
+}
+
+func (e *msgError) Error() string {
+	return Msg(e.id) + e.suffix
+}
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+var (
+	jitterMin  time.Duration
+	jitterMax  time.Duration
+	jitterNext = make(map[string]time.Time)
+	jitterLock sync.Mutex
+)
+
+// 解析 -jitter 100-500ms,前半部分没有单位时沿用后半部分的单位
+func ParseJitter() {
+	if Jitter == "" {
+		return
+	}
+	var err error
+	jitterMin, jitterMax, err = parseJitterRange(Jitter)
+	if err != nil {
+		fmt.Println("[-] jitter parse error:", err)
+		os.Exit(0)
+	}
+}
+
+func parseJitterRange(s string) (time.Duration, time.Duration, error) {
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) == 1 {
+		parts = append(parts, parts[0])
+	}
+	if unit := strings.TrimLeftFunc(parts[1], func(r rune) bool { return unicode.IsDigit(r) || r == '.' }); unit != "" && strings.IndexFunc(parts[0], unicode.IsLetter) < 0 {
+		parts[0] += unit
+	}
+	low, err := time.ParseDuration(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, err
+	}
+	high, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, err
+	}
+	if low < 0 || high < low {
+		return 0, 0, fmt.Errorf("invalid range %q, as: -jitter 100-500ms", s)
+	}
+	return low, high, nil
+}
+
+// 同一主机的相邻两次连接之间随机间隔,和全局限速互不影响
+func WaitJitter(address string) {
+	if jitterMax <= 0 {
+		return
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	delay := jitterMin
+	if jitterMax > jitterMin {
+		delay += time.Duration(RandInt63n(int64(jitterMax - jitterMin)))
+	}
+	jitterLock.Lock()
+	now := time.Now()
+	next, ok := jitterNext[host]
+	if !ok || next.Before(now) {
+		next = now
+	}
+	jitterNext[host] = next.Add(delay)
+	jitterLock.Unlock()
+	if wait := next.Sub(now); wait > 0 {
+		time.Sleep(wait)
+	}
+}
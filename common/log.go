@@ -7,6 +7,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -37,11 +38,26 @@ func init() {
 func LogSuccess(result string) {
 	LogWG.Add(1)
 	LogSucTime = time.Now().Unix()
+	countFinding(result)
 	Results <- &result
 }
 
 func SaveLog() {
 	for result := range Results {
+		if Duplicated(*result) {
+			LogWG.Done()
+			continue
+		}
+		if name := ResultHostName(*result); name != "" {
+			*result += " [host:" + name + "]"
+		}
+		if tag := ResultTag(*result); tag != "" {
+			*result += " [tag:" + tag + "]"
+		}
+		if FilterTag != "" && resultHost(*result, nil) != "" && !strings.HasSuffix(*result, "[tag:"+FilterTag+"]") {
+			LogWG.Done()
+			continue
+		}
 		if !Silent {
 			if Nocolor {
 				fmt.Println(*result)
@@ -55,7 +71,10 @@ func SaveLog() {
 				}
 			}
 		}
-		if IsSave {
+		recordFinding(*result)
+		if Format == "jsonl" && (IsSave || jsonlOut != nil) {
+			WriteJsonl(*result)
+		} else if IsSave && (Format == "" || Format == "txt") {
 			WriteFile(*result, Outputfile)
 		}
 		LogWG.Done()
@@ -63,11 +82,7 @@ func SaveLog() {
 }
 
 func WriteFile(result string, filename string) {
-	fl, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
-	if err != nil {
-		fmt.Printf("Open %s error, %v\n", filename, err)
-		return
-	}
+	var data []byte
 	if JsonOutput {
 		var scantype string
 		var text string
@@ -102,15 +117,63 @@ func WriteFile(result string, filename string) {
 				jsonData = []byte(result)
 			}
 		}
-		jsonData = append(jsonData, []byte(",\n")...)
-		_, err = fl.Write(jsonData)
+		data = append(jsonData, []byte(",\n")...)
 	} else {
-		_, err = fl.Write([]byte(result + "\n"))
+		data = []byte(result + "\n")
+	}
+	AppendOutput(filename, data)
+}
+
+// 结果文件写入失败时的备用输出
+var (
+	fallbackFile string
+	fallbackMu   sync.Mutex
+)
+
+// 追加写结果文件,失败时重试几次(应对短暂错误),仍失败则切换到临时目录下的文件,
+// 临时文件也写不了就直接输出到标准输出,保证结果不丢
+func AppendOutput(filename string, data []byte) {
+	fallbackMu.Lock()
+	defer fallbackMu.Unlock()
+	if fallbackFile != "" {
+		filename = fallbackFile
+	}
+	var err error
+	for i := 0; i < 3; i++ {
+		if err = appendFile(filename, data); err == nil {
+			return
+		}
+		time.Sleep(time.Duration(i+1) * 100 * time.Millisecond)
+	}
+	if filename != "-" {
+		fallback := filepath.Join(os.TempDir(), fmt.Sprintf("fscan-result-%d.txt", os.Getpid()))
+		if filename == fallback {
+			fallback = "-"
+		}
+		fmt.Fprintf(os.Stderr, "[!] Write %s error: %v, results are now written to %s\n", filename, err, fallback)
+		fallbackFile = fallback
+		if fallback != "-" && appendFile(fallback, data) == nil {
+			return
+		}
+		fallbackFile = "-"
 	}
-	fl.Close()
+	os.Stdout.Write(data)
+}
+
+func appendFile(filename string, data []byte) error {
+	if filename == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	fl, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
-		fmt.Printf("Write %s error, %v\n", filename, err)
+		return err
+	}
+	_, err = fl.Write(data)
+	if closeErr := fl.Close(); err == nil {
+		err = closeErr
 	}
+	return err
 }
 
 func LogError(errinfo interface{}) {
@@ -0,0 +1,199 @@
+package common
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 多客户/多范围扫描,每个范围一行:  名称: fscan参数
+//
+//	clientA: -h 10.0.0.0/24 -p 22,80 -brute-rate 5
+//	clientB: -hf b.txt -o b-result.txt
+//
+// 各范围在本进程中依次运行,每个范围的参数都从默认值开始重新解析,
+// 上一个范围的目标、口令字典、跳转范围、结果去重等状态在开始前清空,互不影响;
+// 未指定 -o 时输出到 <名称>.txt,名称中文件名不允许的字符替换为 _
+type Scope struct {
+	Name string
+	Args []string
+}
+
+var scopeFileReg = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// 范围名称对应的文件名,去掉路径分隔符、.. 等,只保留字母数字和 _-
+func scopeFileName(name string) string {
+	return scopeFileReg.ReplaceAllString(name, "_")
+}
+
+func ParseScopes(filename string) ([]Scope, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var scopes []Scope
+	names := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		index := strings.Index(line, ":")
+		if index <= 0 {
+			return nil, fmt.Errorf("invalid scope %q, as: name: -h 10.0.0.0/24", line)
+		}
+		scope := Scope{Name: strings.TrimSpace(line[:index]), Args: splitArgs(line[index+1:])}
+		// 不同名称可能对应同一个文件名,如 a/b 和 a_b
+		if other, ok := names[scopeFileName(scope.Name)]; ok {
+			return nil, fmt.Errorf("duplicate scope name %q and %q", other, scope.Name)
+		}
+		names[scopeFileName(scope.Name)] = scope.Name
+		if _, err := scopeFlags(scope, &HostInfo{}); err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, scanner.Err()
+}
+
+// 在新的 FlagSet 上按默认值重新定义参数并解析该范围的参数,
+// 用 FlagSet 记录的已设置参数判断 -o,-o x、-o=x、--o x 等写法都能识别
+func scopeFlags(scope Scope, Info *HostInfo) (*flag.FlagSet, error) {
+	fs := flag.NewFlagSet(scope.Name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	*Info = HostInfo{}
+	defineFlags(fs, Info)
+	if err := fs.Parse(scope.Args); err != nil {
+		return nil, fmt.Errorf("scope %q: %v", scope.Name, err)
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("scope %q: unexpected argument %q", scope.Name, fs.Arg(0))
+	}
+	hasOutput := false
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "scopes", "dry-run", "validate":
+			// 后两者检查完就退出进程,会中断后面的范围
+			err = fmt.Errorf("scope %q can not use -%s", scope.Name, f.Name)
+		case "o":
+			hasOutput = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if Outputfile == "-" {
+		return nil, fmt.Errorf("scope %q can not write results to stdout", scope.Name)
+	}
+	if !hasOutput {
+		Outputfile = scopeFileName(scope.Name) + ".txt"
+	}
+	return fs, nil
+}
+
+// 按空白分割参数,支持双引号
+func splitArgs(line string) []string {
+	var args []string
+	var current strings.Builder
+	inQuote, hasArg := false, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			hasArg = true
+		case (r == ' ' || r == '\t') && !inQuote:
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if hasArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// 依次运行所有范围,run 为一次完整的解析和扫描(main 中的 Parse 和 Scan),Info 与 run 中使用的是同一个
+func RunScopes(filename string, Info *HostInfo, run func()) {
+	scopes, err := ParseScopes(filename)
+	if err != nil {
+		fmt.Println("[-] scopes parse error:", err)
+		os.Exit(0)
+	}
+	userdict := make(map[string][]string, len(Userdict))
+	for name, users := range Userdict {
+		userdict[name] = append([]string(nil), users...)
+	}
+	passwords := append([]string(nil), Passwords...)
+	for i, scope := range scopes {
+		if i > 0 {
+			resetRunState(userdict, passwords)
+		}
+		fs, _ := scopeFlags(scope, Info)
+		flag.CommandLine = fs
+		fmt.Printf("[*] scope %s started: %s\n", scope.Name, strings.Join(scope.Args, " "))
+		run()
+		fmt.Printf("[*] scope %s finished, results in %s\n", scope.Name, Outputfile)
+	}
+}
+
+var scopeResets []func()
+
+// 注册范围之间需要清空的状态,供 Plugins 等包在 init 中调用
+func OnScopeReset(reset func()) {
+	scopeResets = append(scopeResets, reset)
+}
+
+// 清空上一个范围运行中产生的全局状态;参数绑定的变量由 scopeFlags 重新定义时恢复默认值
+func resetRunState(userdict map[string][]string, passwords []string) {
+	Userdict = make(map[string][]string, len(userdict))
+	for name, users := range userdict {
+		Userdict[name] = append([]string(nil), users...)
+	}
+	Passwords = append([]string(nil), passwords...)
+	Urls, HostPort, AllowNets, Probes, Findings = nil, nil, nil, nil, nil
+	TargetURLs = make(map[string]string)
+	HostAliases = make(map[string][]string)
+	ResolvedNames = make(map[string]string)
+	PTRNames = make(map[string]string)
+	ResolveMap = make(map[string]string)
+	HostTags = make(map[string]string)
+	TargetTags = make(map[string]string)
+	PortPlugins = make(map[string]string)
+	GroupPlugins = make(map[string][]string)
+	HostCredMap = make(map[string][]Cred)
+	scopeHosts = newHostSet()
+	hostStates = make(map[string]HostState)
+	fingerprints = make(map[string]string)
+	fpCache, fpCached = make(map[string]fpCacheEntry), make(map[string]bool)
+	credStats = make(map[string]*credStat)
+	findingCount = make(map[string]int)
+	dedupSeen, dedupBloom = make(map[string]struct{}), nil
+	jitterNext = make(map[string]time.Time)
+	scanWindow, fallbackFile = nil, ""
+	if openPortFile != nil {
+		openPortFile.Close()
+		openPortFile = nil
+	}
+	Num, End = 0, 0
+	RunID = newRunID()
+	// 上一个范围结束时 Scan 关闭了结果通道
+	Results = make(chan *string)
+	go SaveLog()
+	for _, reset := range scopeResets {
+		reset()
+	}
+}
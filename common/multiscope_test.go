@@ -0,0 +1,51 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScopeOutput(t *testing.T) {
+	tests := []struct {
+		line   string
+		output string
+		err    bool
+	}{
+		{"a: -h 10.0.0.1", "a.txt", false},
+		{"b: -h 10.0.0.1 -o b-out.txt", "b-out.txt", false},
+		{"c: -h 10.0.0.1 -o=c-out.txt", "c-out.txt", false},
+		{"d: -h 10.0.0.1 --o d-out.txt", "d-out.txt", false},
+		{"e: -h 10.0.0.1 --o=e-out.txt", "e-out.txt", false},
+		{"../../etc/cron.d/x: -h 10.0.0.1", "______etc_cron_d_x.txt", false},
+		{"f: -h 10.0.0.1 -o -", "", true},
+		{"g: -h 10.0.0.1 -scopes s.txt", "", true},
+		{"h: -h 10.0.0.1 -dry-run", "", true},
+		{"i: -h 10.0.0.1 -no-such-flag", "", true},
+	}
+	for _, tt := range tests {
+		filename := filepath.Join(t.TempDir(), "scopes.txt")
+		if err := os.WriteFile(filename, []byte(tt.line+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		scopes, err := ParseScopes(filename)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: err = %v, want error %v", tt.line, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var info HostInfo
+		if _, err := scopeFlags(scopes[0], &info); err != nil {
+			t.Fatal(err)
+		}
+		if Outputfile != tt.output || info.Host != "10.0.0.1" {
+			t.Errorf("%q: output %q host %q, want %q 10.0.0.1", tt.line, Outputfile, info.Host, tt.output)
+		}
+		if strings.ContainsAny(Outputfile, `/\`) {
+			t.Errorf("%q: output %q escapes the working directory", tt.line, Outputfile)
+		}
+	}
+}
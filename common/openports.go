@@ -0,0 +1,37 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+)
+
+var (
+	openPortFile  *os.File
+	openPortMutex sync.Mutex
+)
+
+// -open-only-output 每发现一个开放端口就追加一行 host:port,方便直接交给其他工具
+func InitOpenPortOutput() {
+	if OpenOnlyOutput == "" {
+		return
+	}
+	file, err := os.OpenFile(OpenOnlyOutput, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		fmt.Println("[-] open-only-output parse error:", err)
+		os.Exit(0)
+	}
+	openPortFile = file
+}
+
+func WriteOpenPort(host string, port int) {
+	if openPortFile == nil {
+		return
+	}
+	openPortMutex.Lock()
+	defer openPortMutex.Unlock()
+	if _, err := fmt.Fprintln(openPortFile, net.JoinHostPort(host, fmt.Sprint(port))); err != nil {
+		fmt.Fprintf(os.Stderr, "[-] Write %s error, %v\n", OpenOnlyOutput, err)
+	}
+}
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// 解析过程中的提示和错误输出,如超出范围、无效ip段、抽样统计
+type Logger interface {
+	Println(a ...interface{})
+}
+
+type stdoutLogger struct{}
+
+func (stdoutLogger) Println(a ...interface{}) {
+	fmt.Println(a...)
+}
+
+// 一次解析的配置,输出、范围上限和端口由字段指定,解析中产生的 host:port、url、域名等都放在 Result 中,
+// 不读写 HostPort 等全局变量,多个 Parser 可以并发使用;-resolve、-sample 等其余选项仍取全局配置
+type Parser struct {
+	Logger   Logger            // 为nil时不输出
+	MaxRange int64             // 单个ip段/cidr展开的最大地址数,0 表示沿用 MaxIPRange
+	NoHosts  string            // 排除列表,写法同 -hn
+	Ports    string            // 与 host:port 去重合并时使用的端口,写法同 -p,为空时不合并
+	Tags     map[string]string // 目标写法 -> 标签,同 -tag,该写法展开出的主机记入 Result.Tags
+}
+
+type Result struct {
+	Hosts    []string
+	HostPort []string            // host:port 形式的目标,ipv6带方括号
+	URLs     map[string]string   // url形式的目标,host:port -> url
+	Names    map[string]string   // -dns-resolve 解析得到的 ip -> 原始域名
+	Aliases  map[string][]string // 去重时合并到该ip的域名
+	Tags     map[string]string   // -tag 或文件行尾 #tag: 标注的主机 -> 标签
+	Sources  map[string]string   // 主机的来源标签,只有 ParseTargets 记录
+}
+
+// ParseIP 等函数使用的默认解析器,输出到标准输出
+var DefaultParser = &Parser{Logger: stdoutLogger{}}
+
+// 一次解析的状态,由 Parser 创建,解析函数都挂在它上面,不同的解析之间不共享
+type parseRun struct {
+	ctx      context.Context
+	logger   Logger
+	maxRange int
+	ports    string
+	tags     map[string]string
+	tagMu    sync.Mutex // res.Tags 在展开协程中写入,ParseIPChan 的过滤协程中读取
+	res      Result
+}
+
+func (p *Parser) newRun(ctx context.Context) *parseRun {
+	maxRange := MaxIPRange
+	if p.MaxRange > 0 {
+		maxRange = int(p.MaxRange)
+	}
+	return &parseRun{
+		ctx:      ctx,
+		logger:   p.Logger,
+		maxRange: maxRange,
+		ports:    p.Ports,
+		tags:     p.Tags,
+		res: Result{
+			URLs:    make(map[string]string),
+			Names:   make(map[string]string),
+			Aliases: make(map[string][]string),
+			Tags:    make(map[string]string),
+		},
+	}
+}
+
+func (r *parseRun) setTag(host, tag string) {
+	r.tagMu.Lock()
+	r.res.Tags[host] = tag
+	r.tagMu.Unlock()
+}
+
+func (r *parseRun) tagOf(host string) string {
+	r.tagMu.Lock()
+	defer r.tagMu.Unlock()
+	return r.res.Tags[host]
+}
+
+func (r *parseRun) log(a ...interface{}) {
+	if r.logger != nil {
+		r.logger.Println(a...)
+	}
+}
+
+func (r *parseRun) logf(format string, a ...interface{}) {
+	r.log(strings.TrimSuffix(fmt.Sprintf(format, a...), "\n"))
+}
+
+func (p *Parser) Parse(host, filename string) (Result, error) {
+	return p.ParseContext(context.Background(), host, filename)
+}
+
+func (p *Parser) ParseContext(ctx context.Context, host, filename string) (Result, error) {
+	var nohosts []string
+	if p.NoHosts != "" {
+		nohosts = []string{p.NoHosts}
+	}
+	return p.newRun(ctx).parse(host, filename, nohosts, false)
+}
+
+// ParseIP 等包级函数使用的解析状态:DefaultParser 加上全局的 -p 端口
+func defaultRun(ctx context.Context) *parseRun {
+	parser := *DefaultParser
+	parser.Ports = Ports
+	parser.Tags = TargetTags
+	return parser.newRun(ctx)
+}
+
+// 把一次解析的结果并入全局的 HostPort、TargetURLs、ResolvedNames 等,供扫描和输出阶段使用
+func publishResult(res Result) {
+	if len(res.HostPort) > 0 {
+		HostPort = RemoveDuplicate(append(HostPort, res.HostPort...))
+	}
+	for target, u := range res.URLs {
+		TargetURLs[target] = u
+	}
+	resolveMutex.Lock()
+	for ip, name := range res.Names {
+		if _, ok := ResolvedNames[ip]; !ok {
+			ResolvedNames[ip] = name
+		}
+	}
+	for ip, names := range res.Aliases {
+		HostAliases[ip] = names
+	}
+	resolveMutex.Unlock()
+	for host, tag := range res.Tags {
+		SetHostTag(host, tag)
+	}
+}
+
+// 以 DefaultParser 解析并把结果并入全局变量
+func parseDefault(ctx context.Context, host, filename string, nohosts []string, withSource bool) (Result, error) {
+	res, err := defaultRun(ctx).parse(host, filename, nohosts, withSource)
+	publishResult(res)
+	return res, err
+}
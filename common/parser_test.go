@@ -0,0 +1,128 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type recordLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordLogger) Println(a ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, strings.TrimSuffix(fmt.Sprintln(a...), "\n"))
+}
+
+func (l *recordLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.lines, "\n")
+}
+
+func TestParserParallel(t *testing.T) {
+	// 同一输入:small 的上限拒绝 /24,large 的上限允许
+	small := &recordLogger{}
+	large := &recordLogger{}
+	tests := []struct {
+		name   string
+		parser *Parser
+		logger *recordLogger
+		target string
+		hosts  int
+		limit  bool
+	}{
+		{"small", &Parser{Logger: small, MaxRange: 16}, small, "10.1.0.1:8080", 0, true},
+		{"large", &Parser{Logger: large, MaxRange: 1 << 16}, large, "10.2.0.1:9090", 256, false},
+	}
+
+	const rounds = 50
+	var wg sync.WaitGroup
+	errs := make(chan string, rounds*len(tests))
+	for i := 0; i < rounds; i++ {
+		for _, tt := range tests {
+			wg.Add(1)
+			go func(name string, p *Parser, target string, hosts int) {
+				defer wg.Done()
+				res, _ := p.Parse("10.0.0.0/24", "")
+				if len(res.Hosts) != hosts {
+					errs <- fmt.Sprintf("%s: got %d hosts, want %d", name, len(res.Hosts), hosts)
+				}
+				res, _ = p.Parse(target, "")
+				if len(res.HostPort) != 1 || res.HostPort[0] != target {
+					errs <- fmt.Sprintf("%s: got HostPort %v, want [%s]", name, res.HostPort, target)
+				}
+			}(tt.name, tt.parser, tt.target, tt.hosts)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for _, tt := range tests {
+		out := tt.logger.String()
+		if got := strings.Contains(out, "10.0.0.0/24"); got != tt.limit {
+			t.Errorf("%s: range limit logged = %v, want %v, output:\n%s", tt.name, got, tt.limit, out)
+		}
+		other := "10.2.0.1"
+		if tt.name == "large" {
+			other = "10.1.0.1"
+		}
+		if strings.Contains(out, other) {
+			t.Errorf("%s: logger got output of the other parser:\n%s", tt.name, out)
+		}
+	}
+	if len(HostPort) != 0 {
+		t.Errorf("Parser wrote global HostPort: %v", HostPort)
+	}
+}
+
+func TestParserTags(t *testing.T) {
+	// /8 只展开抽样的主机,标签要落在实际选中的主机上
+	p := &Parser{Tags: map[string]string{"10.0.0.0/8": "lab", "192.168.1.0/30": "prod"}}
+	res, err := p.Parse("10.0.0.0/8,192.168.1.0/30", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Hosts) == 0 || len(res.Tags) != len(res.Hosts) {
+		t.Fatalf("got %d tags for %d hosts", len(res.Tags), len(res.Hosts))
+	}
+	for _, host := range res.Hosts {
+		want := "lab"
+		if strings.HasPrefix(host, "192.168.1.") {
+			want = "prod"
+		}
+		if res.Tags[host] != want {
+			t.Errorf("tag of %s = %q, want %q", host, res.Tags[host], want)
+		}
+	}
+}
+
+func TestResultTag(t *testing.T) {
+	old := HostTags
+	defer func() { HostTags = old }()
+	HostTags = map[string]string{"10.0.0.1": "prod", "2001:db8::1": "v6", "example.com": "web"}
+	tests := []struct {
+		result string
+		want   string
+	}{
+		{"[+] mysql 10.0.0.1:3306:root 123456", "prod"},
+		{"10.0.0.1:22 open", "prod"},
+		{"[*] WebTitle http://[2001:db8::1]:8080 code:200", "v6"},
+		{"[+] Tarpit 2001:db8::1 open ports > 100", "v6"},
+		{"[*] WebTitle https://example.com/login code:200", "web"},
+		{"[+] mysql 10.0.0.2:3306:root 123456 from 10.0.0.1", ""},
+		{"[*] start scan", ""},
+	}
+	for _, tt := range tests {
+		if got := ResultTag(tt.result); got != tt.want {
+			t.Errorf("ResultTag(%q) = %q, want %q", tt.result, got, tt.want)
+		}
+	}
+}
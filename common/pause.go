@@ -0,0 +1,32 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+)
+
+// 暂停开关,暂停时不再派发新任务,已在执行的任务正常完成
+var (
+	paused    bool
+	pauseCond = sync.NewCond(&sync.Mutex{})
+)
+
+func TogglePause() {
+	pauseCond.L.Lock()
+	paused = !paused
+	if paused {
+		fmt.Printf("[*] paused, 已完成 %v/%v, send SIGUSR1 again to resume\n", End, Num)
+	} else {
+		fmt.Println("[*] resumed")
+	}
+	pauseCond.L.Unlock()
+	pauseCond.Broadcast()
+}
+
+func WaitPause() {
+	pauseCond.L.Lock()
+	for paused {
+		pauseCond.Wait()
+	}
+	pauseCond.L.Unlock()
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package common
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// kill -USR1 <pid> 切换暂停/继续
+func init() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	go func() {
+		for range ch {
+			TogglePause()
+		}
+	}()
+}
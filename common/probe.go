@@ -0,0 +1,84 @@
+package common
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 自定义探测包,连接后先发送payload再读响应,用于识别不主动发banner的私有服务
+type Probe struct {
+	Name    string
+	Ports   []string
+	Payload []byte
+	Match   *regexp.Regexp
+}
+
+var Probes []Probe
+
+// 探测定义文件每行一条: 名称|端口(逗号分隔,*为所有端口)|payload|匹配正则(可选)
+// payload 以 hex: 或 str: 开头,str 支持 \r\n 等转义,如:
+// myproto|9999,10000|str:HELLO\r\n|^OK myproto
+func ParseProbes(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, "|", 4)
+		if len(fields) < 3 {
+			return fmt.Errorf("invalid probe %q", line)
+		}
+		probe := Probe{Name: strings.TrimSpace(fields[0])}
+		for _, port := range strings.Split(fields[1], ",") {
+			probe.Ports = append(probe.Ports, strings.TrimSpace(port))
+		}
+		switch payload := fields[2]; {
+		case strings.HasPrefix(payload, "hex:"):
+			probe.Payload, err = hex.DecodeString(payload[4:])
+		case strings.HasPrefix(payload, "str:"):
+			var text string
+			text, err = strconv.Unquote(`"` + strings.ReplaceAll(payload[4:], `"`, `\"`) + `"`)
+			probe.Payload = []byte(text)
+		default:
+			err = fmt.Errorf("payload must start with hex: or str:")
+		}
+		if err != nil {
+			return fmt.Errorf("invalid probe %q: %v", line, err)
+		}
+		if len(fields) == 4 && fields[3] != "" {
+			if probe.Match, err = regexp.Compile(fields[3]); err != nil {
+				return fmt.Errorf("invalid probe %q: %v", line, err)
+			}
+		}
+		Probes = append(Probes, probe)
+	}
+	return scanner.Err()
+}
+
+// 返回适用于该端口的探测包,指定端口的排在通配的前面
+func ProbesFor(port string) []Probe {
+	var exact, any []Probe
+	for _, probe := range Probes {
+		for _, p := range probe.Ports {
+			if p == port {
+				exact = append(exact, probe)
+				break
+			} else if p == "*" {
+				any = append(any, probe)
+				break
+			}
+		}
+	}
+	return append(exact, any...)
+}
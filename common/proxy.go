@@ -1,6 +1,7 @@
 package common
 
 import (
+	"context"
 	"errors"
 	"golang.org/x/net/proxy"
 	"net"
@@ -15,11 +16,19 @@ func WrapperTcpWithTimeout(network, address string, timeout time.Duration) (net.
 }
 
 func WrapperTCP(network, address string, forward *net.Dialer) (net.Conn, error) {
+	address = ResolveAddr(JoinAddr(address))
+	WaitJitter(address)
 	//get conn
 	var conn net.Conn
 	if Socks5Proxy == "" {
 		var err error
 		conn, err = forward.Dial(network, address)
+		if err != nil && ConnectRetry > 0 && isTimeout(err) {
+			// 两段式超时:先用 -time 的短超时,超时后用 -connect-retry 的长超时再连一次
+			retry := *forward
+			retry.Timeout = time.Duration(ConnectRetry) * time.Second
+			conn, err = retry.Dial(network, address)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -33,8 +42,87 @@ func WrapperTCP(network, address string, forward *net.Dialer) (net.Conn, error)
 			return nil, err
 		}
 	}
-	return conn, nil
+	return newAttemptConn(conn), nil
+
+}
 
+// -attempt-timeout 指定单次尝试(连接+握手+认证)的总时限,插件后续设置的deadline不能超过它,
+// 防止恶意/tarpit服务在握手阶段一直占住线程;认证成功后的redis写入等长操作用 EndAttempt 解除
+type attemptConn struct {
+	net.Conn
+	deadline time.Time
+}
+
+func newAttemptConn(conn net.Conn) net.Conn {
+	attempt := time.Duration(AttemptTimeout) * time.Second
+	if attempt <= 0 {
+		return conn
+	}
+	c := &attemptConn{Conn: conn, deadline: time.Now().Add(attempt)}
+	c.Conn.SetDeadline(c.deadline)
+	return c
+}
+
+func (c *attemptConn) limit(t time.Time) time.Time {
+	if c.deadline.IsZero() {
+		return t
+	}
+	if t.IsZero() || t.After(c.deadline) {
+		return c.deadline
+	}
+	return t
+}
+
+// 认证已完成,之后的操作只受插件自己设置的deadline限制
+func EndAttempt(conn net.Conn) {
+	if c, ok := conn.(*attemptConn); ok {
+		c.deadline = time.Time{}
+		c.Conn.SetWriteDeadline(time.Time{})
+	}
+}
+
+// 单次尝试的context,-plugin-timeout 超时时一并取消,用于数据库驱动的 PingContext
+func AttemptContext(info *HostInfo) (context.Context, context.CancelFunc) {
+	if AttemptTimeout > 0 {
+		return context.WithTimeout(info.Context(), time.Duration(AttemptTimeout)*time.Second)
+	}
+	return context.WithCancel(info.Context())
+}
+
+func (c *attemptConn) SetDeadline(t time.Time) error {
+	return c.Conn.SetDeadline(c.limit(t))
+}
+
+func (c *attemptConn) SetReadDeadline(t time.Time) error {
+	return c.Conn.SetReadDeadline(c.limit(t))
+}
+
+func (c *attemptConn) SetWriteDeadline(t time.Time) error {
+	return c.Conn.SetWriteDeadline(c.limit(t))
+}
+
+// 插件里常用 host:port 拼接地址,ipv6(含%zone)需要加上方括号才能拨号
+func JoinAddr(address string) string {
+	if strings.Count(address, ":") < 2 || strings.HasPrefix(address, "[") {
+		return address
+	}
+	index := strings.LastIndex(address, ":")
+	return net.JoinHostPort(address[:index], address[index+1:])
+}
+
+// 按 -resolve 指定的静态解析替换连接地址,类似curl的--resolve
+func ResolveAddr(address string) string {
+	if len(ResolveMap) == 0 {
+		return address
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	if ip, ok := ResolveMap[strings.ToLower(host)]; ok {
+		return net.JoinHostPort(ip, port)
+	}
+	return address
 }
 
 func Socks5Dailer(forward *net.Dialer) (proxy.Dialer, error) {
@@ -63,3 +151,8 @@ func Socks5Dailer(forward *net.Dialer) (proxy.Dialer, error) {
 	}
 	return dailer, nil
 }
+
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
@@ -0,0 +1,38 @@
+package common
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// 全局随机数源,/8抽样、子网抽样、jitter等都从这里取;
+// 指定 -rand-seed 时同样的输入每次生成相同的目标列表,便于复现和diff
+var (
+	Rand      = rand.New(rand.NewSource(time.Now().UnixNano()))
+	randMutex sync.Mutex
+)
+
+func InitRand() {
+	if RandSeed != 0 {
+		Rand = rand.New(rand.NewSource(RandSeed))
+	}
+}
+
+func RandInt63n(n int64) int64 {
+	randMutex.Lock()
+	defer randMutex.Unlock()
+	return Rand.Int63n(n)
+}
+
+func RandIntn(n int) int {
+	randMutex.Lock()
+	defer randMutex.Unlock()
+	return Rand.Intn(n)
+}
+
+func RandPerm(n int) []int {
+	randMutex.Lock()
+	defer randMutex.Unlock()
+	return Rand.Perm(n)
+}
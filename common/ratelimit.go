@@ -0,0 +1,58 @@
+package common
+
+import (
+	"sync"
+	"time"
+)
+
+// 按固定间隔放行的限速器,rate为每秒次数,nil表示不限速
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+var (
+	DiscoveryLimiter *RateLimiter
+	BruteLimiter     *RateLimiter
+	HostLimiter      *RateLimiter
+	BruteSem         chan struct{}
+)
+
+func NewRateLimiter(rate int) *RateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &RateLimiter{interval: time.Second / time.Duration(rate)}
+}
+
+func (r *RateLimiter) Wait() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	wait := r.next.Sub(now)
+	r.next = r.next.Add(r.interval)
+	r.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// 各阶段独立限速:
+// -discovery-rate/-discovery-threads 只作用于端口扫描的连接,
+// -brute-rate/-brute-threads 只作用于口令爆破的每次尝试和同时运行的爆破任务数,
+// 两者都在 -t 全局线程数之内生效,即实际并发取两者中较小的值;
+// -rate 限制每秒产出的新主机数,作用在目标生成的出口、任何探测之前,与目标来自cidr、ip段、文件还是标准输入无关
+func InitPhaseLimit() {
+	DiscoveryLimiter = NewRateLimiter(DiscoveryRate)
+	HostLimiter = NewRateLimiter(RateLimit)
+	BruteLimiter = NewRateLimiter(BruteRate)
+	if BruteThreads > 0 {
+		BruteSem = make(chan struct{}, BruteThreads)
+	}
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	tests := []struct {
+		rate, n, workers int
+	}{
+		{100, 20, 1},
+		{100, 20, 8},
+		{500, 50, 16},
+	}
+	for _, tt := range tests {
+		limiter := NewRateLimiter(tt.rate)
+		start := time.Now()
+		var wg sync.WaitGroup
+		for w := 0; w < tt.workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := w; i < tt.n; i += tt.workers {
+					limiter.Wait()
+				}
+			}(w)
+		}
+		wg.Wait()
+		// 第一次不等待,其余每次间隔 1/rate
+		least := time.Duration(tt.n-1) * time.Second / time.Duration(tt.rate)
+		if elapsed := time.Since(start); elapsed < least {
+			t.Errorf("rate %d, %d waits by %d workers took %v, want at least %v", tt.rate, tt.n, tt.workers, elapsed, least)
+		}
+	}
+}
+
+func TestNewRateLimiterOff(t *testing.T) {
+	for _, rate := range []int{0, -1} {
+		if limiter := NewRateLimiter(rate); limiter != nil {
+			t.Errorf("NewRateLimiter(%d) = %v, want nil", rate, limiter)
+		}
+	}
+	// nil 限速器直接放行
+	var limiter *RateLimiter
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		limiter.Wait()
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("nil limiter took %v", elapsed)
+	}
+}
+
+func TestParseIPChanRate(t *testing.T) {
+	old := HostLimiter
+	defer func() { HostLimiter = old }()
+
+	tests := []struct {
+		host    string
+		rate    int
+		count   int
+		readers int
+	}{
+		{"10.0.0.0/28", 100, 16, 1},
+		{"10.0.0.0/27", 200, 32, 8},
+	}
+	for _, tt := range tests {
+		HostLimiter = NewRateLimiter(tt.rate)
+		start := time.Now()
+		stream, err := ParseIPChan(tt.host, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var mu sync.Mutex
+		var got int
+		var wg sync.WaitGroup
+		for i := 0; i < tt.readers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range stream {
+					mu.Lock()
+					got++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if got != tt.count {
+			t.Errorf("ParseIPChan(%q) got %d hosts, want %d", tt.host, got, tt.count)
+		}
+		least := time.Duration(tt.count-1) * time.Second / time.Duration(tt.rate)
+		if elapsed := time.Since(start); elapsed < least {
+			t.Errorf("ParseIPChan(%q) at rate %d with %d readers took %v, want at least %v", tt.host, tt.rate, tt.readers, elapsed, least)
+		}
+	}
+}
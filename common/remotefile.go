@@ -0,0 +1,99 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// -hf 为 http(s) 地址时从远程下载目标列表,走 -proxy/-socks5,
+// 指定 -hf-cache 时按ETag缓存,内容未变化(304)直接使用本地缓存;
+// -hf - 从标准输入逐行读取,上游持续输出时边读边解析
+func OpenTargetFile(filename string) (io.ReadCloser, error) {
+	if filename == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	if !strings.HasPrefix(filename, "http://") && !strings.HasPrefix(filename, "https://") {
+		return os.Open(filename)
+	}
+	client, err := remoteClient()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", filename, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	for _, header := range strings.Split(HostFileHeader, ";") {
+		kv := strings.SplitN(header, ":", 2)
+		if len(kv) == 2 {
+			req.Header.Set(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+		}
+	}
+	if HostFileCache != "" {
+		if etag, err := os.ReadFile(HostFileCache + ".etag"); err == nil {
+			if _, err := os.Stat(HostFileCache); err == nil {
+				req.Header.Set("If-None-Match", strings.TrimSpace(string(etag)))
+			}
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && HostFileCache != "":
+		resp.Body.Close()
+		fmt.Println("[*] target list not modified, use cache", HostFileCache)
+		return os.Open(HostFileCache)
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetch %s got status %s", filename, resp.Status)
+	}
+	if HostFileCache == "" {
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(HostFileCache, data, 0666); err != nil {
+		fmt.Printf("[-] Write %s error, %v\n", HostFileCache, err)
+	} else if etag := resp.Header.Get("ETag"); etag != "" {
+		os.WriteFile(HostFileCache+".etag", []byte(etag), 0666)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func remoteClient() (*http.Client, error) {
+	dialer := &net.Dialer{Timeout: time.Duration(WebTimeout) * time.Second}
+	tr := &http.Transport{DialContext: dialer.DialContext}
+	if Socks5Proxy != "" {
+		dialSocksProxy, err := Socks5Dailer(dialer)
+		if err != nil {
+			return nil, err
+		}
+		contextDialer, ok := dialSocksProxy.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("failed type assertion to DialContext")
+		}
+		tr.DialContext = contextDialer.DialContext
+	} else if Proxy != "" {
+		u, err := url.Parse(Proxy)
+		if err != nil {
+			return nil, err
+		}
+		tr.Proxy = http.ProxyURL(u)
+	}
+	return &http.Client{Transport: tr, Timeout: 5 * time.Minute}, nil
+}
@@ -0,0 +1,225 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// 汇总后的单条结果,用于 -format 导出
+type Finding struct {
+	Host     string
+	Port     string
+	Type     string
+	Text     string
+	Severity string
+}
+
+var (
+	Findings      []Finding
+	findingsMutex sync.Mutex
+	severityReg   = regexp.MustCompile(`\[(critical|high|medium|low|info)\]`)
+	severityRank  = map[string]int{"info": 0, "low": 1, "medium": 2, "high": 3, "critical": 4}
+	severityColor = map[string]string{"info": "gray", "low": "gold", "medium": "orange", "high": "red", "critical": "darkred"}
+)
+
+// 从结果行中提取主机、端口、类型和等级,没有等级标记时 [+] 记为high,其他为info
+func ParseFinding(result string) (finding Finding, ok bool) {
+	hostport := hostPortReg.FindString(result)
+	if hostport == "" {
+		return
+	}
+	finding.Host = hostport
+	if index := strings.Index(hostport, ":"); index != -1 {
+		finding.Host, finding.Port = hostport[:index], hostport[index+1:]
+	}
+	finding.Text = result
+	finding.Severity = "info"
+	switch {
+	case strings.HasPrefix(result, "[+]"), strings.HasPrefix(result, "[*]"):
+		fields := strings.Fields(result)
+		if len(fields) > 1 {
+			finding.Type = fields[1]
+		}
+		if strings.HasPrefix(result, "[+]") {
+			finding.Severity = "high"
+		}
+	case strings.HasSuffix(result, " open"):
+		finding.Type = "open"
+	default:
+		return
+	}
+	if finds := severityReg.FindAllStringSubmatch(result, -1); len(finds) > 0 {
+		finding.Severity = finds[len(finds)-1][1]
+	}
+	return finding, true
+}
+
+func recordFinding(result string) {
+	if Format != "dot" && Format != "sarif" {
+		return
+	}
+	if finding, ok := ParseFinding(result); ok {
+		findingsMutex.Lock()
+		Findings = append(Findings, finding)
+		findingsMutex.Unlock()
+	}
+}
+
+// -format jsonl 每行一个json对象,写完即落盘,中断时已输出的内容仍是合法的NDJSON
+type JsonLine struct {
+	Time       string `json:"time"`
+	Type       string `json:"type"`
+	Host       string `json:"host,omitempty"`
+	Port       string `json:"port,omitempty"`
+	Severity   string `json:"severity,omitempty"`
+	Text       string `json:"text"`
+	Engagement string `json:"engagement,omitempty"`
+	Run        string `json:"run"`
+}
+
+var jsonlOut io.Writer
+
+// -o - 时json流写到标准输出,其余给人看的输出全部改到标准错误
+func InitJsonl() {
+	if Format != "jsonl" || Outputfile != "-" {
+		return
+	}
+	jsonlOut = os.Stdout
+	os.Stdout = os.Stderr
+	color.Output = os.Stderr
+}
+
+func WriteJsonl(result string) {
+	line := JsonLine{Time: time.Now().Format(time.RFC3339), Type: "msg", Text: result, Engagement: EngagementID, Run: RunID}
+	if finding, ok := ParseFinding(result); ok {
+		line.Host, line.Port, line.Severity = finding.Host, finding.Port, finding.Severity
+		if finding.Type != "" {
+			line.Type = finding.Type
+		}
+	} else if fields := strings.Fields(result); len(fields) > 1 && strings.HasPrefix(result, "[") {
+		line.Type = fields[1]
+	}
+	data, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+	if jsonlOut != nil {
+		jsonlOut.Write(data)
+		return
+	}
+	AppendOutput(Outputfile, data)
+}
+
+// -format 非文本格式时,扫描结束后统一写出
+func WriteReport() {
+	var data string
+	switch Format {
+	case "dot":
+		data = FindingsDot()
+	case "sarif":
+		data = FindingsSarif()
+	default:
+		return
+	}
+	filename := Outputfile
+	if filename == "result.txt" {
+		filename = strings.TrimSuffix(filename, filepath.Ext(filename)) + "." + Format
+	}
+	if err := os.WriteFile(filename, []byte(data), 0666); err != nil {
+		fmt.Printf("[-] Write %s error, %v\n", filename, err)
+		return
+	}
+	fmt.Println("[*] report saved to " + filename)
+}
+
+// 主机为节点,服务挂在主机下,按最高等级着色,节点id由host/port生成,多次运行可直接diff
+func FindingsDot() string {
+	findingsMutex.Lock()
+	defer findingsMutex.Unlock()
+	type node struct {
+		types    []string
+		severity string
+	}
+	hosts := make(map[string]*node)
+	services := make(map[string]*node)
+	update := func(nodes map[string]*node, key string, finding Finding) {
+		n, ok := nodes[key]
+		if !ok {
+			n = &node{severity: "info"}
+			nodes[key] = n
+		}
+		if finding.Type != "" && finding.Type != "open" {
+			label := strings.ReplaceAll(finding.Type, "\"", "'")
+			found := false
+			for _, t := range n.types {
+				if t == label {
+					found = true
+				}
+			}
+			if !found {
+				n.types = append(n.types, label)
+			}
+		}
+		if severityRank[finding.Severity] > severityRank[n.severity] {
+			n.severity = finding.Severity
+		}
+	}
+	for _, finding := range Findings {
+		if finding.Port == "" {
+			update(hosts, finding.Host, finding)
+			continue
+		}
+		if _, ok := hosts[finding.Host]; !ok {
+			hosts[finding.Host] = &node{severity: "info"}
+		}
+		update(services, finding.Host+":"+finding.Port, finding)
+	}
+	var hostKeys, serviceKeys []string
+	for key := range hosts {
+		hostKeys = append(hostKeys, key)
+	}
+	for key := range services {
+		serviceKeys = append(serviceKeys, key)
+	}
+	sort.Strings(hostKeys)
+	sort.Strings(serviceKeys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// run: %s\n", RunID)
+	if EngagementID != "" {
+		fmt.Fprintf(&b, "// engagement: %s\n", EngagementID)
+	}
+	b.WriteString("digraph fscan {\n\trankdir=LR;\n\tnode [style=filled, fillcolor=white];\n")
+	for _, host := range hostKeys {
+		n := hosts[host]
+		label := host
+		if len(n.types) > 0 {
+			label += "\\n" + strings.Join(n.types, "\\n")
+		}
+		fmt.Fprintf(&b, "\t\"host_%s\" [shape=box, label=\"%s\", color=%s];\n", host, label, severityColor[n.severity])
+	}
+	for _, key := range serviceKeys {
+		n := services[key]
+		index := strings.LastIndex(key, ":")
+		host, port := key[:index], key[index+1:]
+		label := port
+		if len(n.types) > 0 {
+			label += "\\n" + strings.Join(n.types, "\\n")
+		}
+		fmt.Fprintf(&b, "\t\"svc_%s_%s\" [shape=ellipse, label=\"%s\", color=%s];\n", host, port, label, severityColor[n.severity])
+		fmt.Fprintf(&b, "\t\"host_%s\" -> \"svc_%s_%s\";\n", host, host, port)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -dns-resolve 解析时记录 ip -> 原始域名,结果中以 [host:域名] 标注
+var (
+	ResolvedNames = make(map[string]string)
+	resolveMutex  sync.RWMutex
+)
+
+// 解析域名的A和AAAA记录,返回全部地址,ip -> 域名记入本次解析的 Names;失败时按 -dns-fail 保留域名原样或跳过
+func (r *parseRun) resolveHost(name string) []string {
+	timeout := time.Duration(Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(r.ctx, timeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, name)
+	if err != nil || len(addrs) == 0 {
+		if DnsFail == "skip" {
+			r.logf("[-] resolve %s error: %v, skipped", name, err)
+			return nil
+		}
+		r.logf("[-] resolve %s error: %v, keep it as hostname", name, err)
+		return []string{name}
+	}
+	var ips []string
+	for _, addr := range addrs {
+		ip := addr.IP.String()
+		if _, ok := r.res.Names[ip]; !ok {
+			r.res.Names[ip] = name
+		}
+		ips = append(ips, ip)
+	}
+	return ips
+}
+
+// -ptr 反查得到的 ip -> 主机名
+var PTRNames = make(map[string]string)
+
+// 反查ip的PTR记录,超时由 -ptr-timeout 控制,有多条时取第一条
+func ResolvePTR(ip string) (string, error) {
+	timeout := time.Duration(PTRTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	names, err := net.DefaultResolver.LookupAddr(ctx, ip)
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("no ptr record for %s", ip)
+	}
+	return strings.TrimSuffix(names[0], "."), nil
+}
+
+// 解析完成后批量反查,并发数由 -ptr-threads 限制,结果写入 PTRNames
+func ResolvePTRs(hosts []string) {
+	if !PTRLookup || len(hosts) == 0 {
+		return
+	}
+	threads := PTRThreads
+	if threads < 1 {
+		threads = 1
+	}
+	ch := make(chan string)
+	var wg sync.WaitGroup
+	var found int
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range ch {
+				name, err := ResolvePTR(ip)
+				if err != nil {
+					continue
+				}
+				resolveMutex.Lock()
+				PTRNames[ip] = name
+				found++
+				resolveMutex.Unlock()
+			}
+		}()
+	}
+	for _, host := range hosts {
+		if net.ParseIP(host) != nil {
+			ch <- host
+		}
+	}
+	close(ch)
+	wg.Wait()
+	fmt.Printf("[*] ptr resolved %d/%d hosts\n", found, len(hosts))
+}
+
+// 查找 ip 的主机名,-ptr 反查的结果优先于 -dns-resolve 解析前的域名
+func HostName(ip string) string {
+	resolveMutex.RLock()
+	defer resolveMutex.RUnlock()
+	if name, ok := PTRNames[ip]; ok {
+		return name
+	}
+	return ResolvedNames[ip]
+}
+
+// 根据结果中的第一个ip查找解析前的域名或PTR记录
+func ResultHostName(result string) string {
+	resolveMutex.RLock()
+	empty := len(ResolvedNames) == 0 && len(PTRNames) == 0
+	resolveMutex.RUnlock()
+	if empty {
+		return ""
+	}
+	return HostName(resultHost(result, nil))
+}
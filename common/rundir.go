@@ -0,0 +1,47 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 每次运行的唯一编号,写入结果头部、jsonl和dot,便于多次扫描之间互相对照
+var RunID = newRunID()
+
+func newRunID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%08x", time.Now().UnixNano()&0xffffffff)
+	}
+	return hex.EncodeToString(b)
+}
+
+// -output-dir 时在其下建立 <时间>-<RunID> 目录,本次运行的结果、资产清单、导出范围、目标json、存活网段等相对路径都放进去;
+// -fp-cache、-resume-file 是跨运行复用的缓存,不放进去
+func SetupRunDir() {
+	if OutputDir == "" {
+		return
+	}
+	dir := filepath.Join(OutputDir, time.Now().Format("20060102-150405")+"-"+RunID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Println("[-] output-dir parse error:", err)
+		os.Exit(0)
+	}
+	for _, artifact := range []*string{&Outputfile, &InventoryFile, &ExportScope, &OpenOnlyOutput, &TargetsJson, &LiveCIDRs} {
+		if *artifact == "" || *artifact == "-" || filepath.IsAbs(*artifact) {
+			continue
+		}
+		*artifact = filepath.Join(dir, *artifact)
+	}
+	meta := fmt.Sprintf("run: %s\nstart: %s\nengagement: %s\nargs: %s\n", RunID, time.Now().Format("2006-01-02 15:04:05"), EngagementID, strings.Join(os.Args[1:], " "))
+	if err := os.WriteFile(filepath.Join(dir, "run.txt"), []byte(meta), 0666); err != nil {
+		fmt.Println("[-] output-dir parse error:", err)
+		os.Exit(0)
+	}
+	fmt.Println("[*] run " + RunID + " output dir: " + dir)
+}
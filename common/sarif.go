@@ -0,0 +1,140 @@
+package common
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// SARIF 2.1.0 最小结构,规则为结果类型,每条结果以 host:port 作为位置
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool              `json:"tool"`
+	Results    []sarifResult          `json:"results"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string            `json:"id"`
+	ShortDescription sarifMessage      `json:"shortDescription"`
+	Properties       map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	RuleIndex  int               `json:"ruleIndex"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysical  `json:"physicalLocation"`
+	LogicalLocations []sarifLogical `json:"logicalLocations"`
+}
+
+type sarifPhysical struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogical struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+var sarifLevel = map[string]string{"info": "note", "low": "note", "medium": "warning", "high": "error", "critical": "error"}
+
+// github按 security-severity 分级: >=9 critical, >=7 high, >=4 medium, 其余low
+var sarifSecuritySeverity = map[string]string{"info": "0.0", "low": "3.0", "medium": "5.0", "high": "8.0", "critical": "9.5"}
+
+func FindingsSarif() string {
+	findingsMutex.Lock()
+	defer findingsMutex.Unlock()
+	ruleSeverity := make(map[string]string)
+	for _, finding := range Findings {
+		ruleType := sarifRuleID(finding)
+		if severityRank[finding.Severity] >= severityRank[ruleSeverity[ruleType]] {
+			ruleSeverity[ruleType] = finding.Severity
+		}
+	}
+	var ruleIDs []string
+	for id := range ruleSeverity {
+		ruleIDs = append(ruleIDs, id)
+	}
+	sort.Strings(ruleIDs)
+	ruleIndex := make(map[string]int)
+	rules := []sarifRule{}
+	for i, id := range ruleIDs {
+		ruleIndex[id] = i
+		rules = append(rules, sarifRule{
+			ID:               id,
+			ShortDescription: sarifMessage{Text: "fscan " + id + " finding"},
+			Properties:       map[string]string{"security-severity": sarifSecuritySeverity[ruleSeverity[id]]},
+		})
+	}
+	results := []sarifResult{}
+	for _, finding := range Findings {
+		id := sarifRuleID(finding)
+		target := finding.Host
+		if finding.Port != "" {
+			target += ":" + finding.Port
+		}
+		results = append(results, sarifResult{
+			RuleID:    id,
+			RuleIndex: ruleIndex[id],
+			Level:     sarifLevel[finding.Severity],
+			Message:   sarifMessage{Text: finding.Text},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysical{ArtifactLocation: sarifArtifact{URI: "tcp://" + target}},
+				LogicalLocations: []sarifLogical{{Name: target, Kind: "module"}},
+			}},
+			Properties: map[string]string{"severity": finding.Severity, "security-severity": sarifSecuritySeverity[finding.Severity]},
+		})
+	}
+	run := sarifRun{
+		Tool:       sarifTool{Driver: sarifDriver{Name: "fscan", InformationURI: "https://github.com/shadow1ng/fscan", Rules: rules}},
+		Results:    results,
+		Properties: map[string]interface{}{"run": RunID},
+	}
+	if EngagementID != "" {
+		run.Properties["engagement"] = EngagementID
+	}
+	data, _ := json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+	return string(data)
+}
+
+func sarifRuleID(finding Finding) string {
+	id := strings.ToLower(strings.Trim(finding.Type, ":"))
+	if id == "" {
+		id = "finding"
+	}
+	return id
+}
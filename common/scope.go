@@ -0,0 +1,282 @@
+package common
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	scopeHosts = newHostSet()
+	scopeMutex sync.RWMutex
+)
+
+// 记录本次扫描的目标范围,用于判断跳转是否越界;可多次调用,流式扫描时每批主机追加一次。
+// ipv4用位图记录,完整展开/8也只占几MB
+func SetScope(hosts []string, urls []string) {
+	scopeMutex.Lock()
+	defer scopeMutex.Unlock()
+	for _, host := range hosts {
+		scopeHosts.add(host)
+	}
+	for _, target := range HostPort {
+		if host, _, err := net.SplitHostPort(target); err == nil {
+			scopeHosts.add(host)
+		}
+	}
+	for _, target := range urls {
+		if !strings.Contains(target, "://") {
+			target = "http://" + target
+		}
+		if u, err := url.Parse(target); err == nil && u.Hostname() != "" {
+			scopeHosts.add(u.Hostname())
+		}
+	}
+}
+
+func InScope(host string) bool {
+	scopeMutex.RLock()
+	defer scopeMutex.RUnlock()
+	if scopeHosts.count == 0 {
+		return true
+	}
+	if scopeHosts.has(host) {
+		return true
+	}
+	// 通过 -resolve 映射的域名按映射后的ip判断
+	if ip, ok := ResolveMap[strings.ToLower(host)]; ok {
+		return scopeHosts.has(ip)
+	}
+	return false
+}
+
+// 同主机跳转(如http->https)始终允许,跳到其他主机时要求在扫描范围内
+func RedirectInScope(from, to string) bool {
+	fromURL, err := url.Parse(from)
+	if err != nil {
+		return false
+	}
+	toURL, err := url.Parse(to)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(fromURL.Hostname(), toURL.Hostname()) {
+		return true
+	}
+	return InScope(toURL.Hostname())
+}
+
+// 把主机列表聚合为最少的CIDR,非ipv4(域名等)原样保留
+func AggregateCIDRs(hosts []string) []string {
+	var ips []uint32
+	var others []string
+	for _, host := range hosts {
+		if ip := net.ParseIP(host).To4(); ip != nil {
+			ips = append(ips, binary.BigEndian.Uint32(ip))
+		} else {
+			others = append(others, host)
+		}
+	}
+	sort.Slice(ips, func(i, j int) bool { return ips[i] < ips[j] })
+	var cidrs []string
+	for i := 0; i < len(ips); {
+		// 找出连续的一段 [start,end]
+		start, end := uint64(ips[i]), uint64(ips[i])
+		for i++; i < len(ips) && uint64(ips[i]) <= end+1; i++ {
+			end = uint64(ips[i])
+		}
+		for start <= end {
+			bits := 0
+			for bits < 32 && start%(1<<(bits+1)) == 0 && start+(1<<(bits+1))-1 <= end {
+				bits++
+			}
+			ip := make(net.IP, 4)
+			binary.BigEndian.PutUint32(ip, uint32(start))
+			cidrs = append(cidrs, fmt.Sprintf("%s/%d", ip, 32-bits))
+			start += 1 << bits
+		}
+	}
+	return append(cidrs, others...)
+}
+
+// 导出最终生效的扫描范围,可直接作为 -hf 复用
+func WriteScope(filename string, hosts []string) error {
+	all := append([]string{}, hosts...)
+	for _, target := range HostPort {
+		if host, _, err := net.SplitHostPort(target); err == nil {
+			all = append(all, host)
+		}
+	}
+	cidrs := AggregateCIDRs(RemoveDuplicate(all))
+	var data string
+	for _, cidr := range cidrs {
+		data += cidr + "\n"
+	}
+	if err := os.WriteFile(filename, []byte(data), 0666); err != nil {
+		return err
+	}
+	fmt.Printf("[*] scope exported to %s, hosts: %d, cidrs: %d\n", filename, len(all), len(cidrs))
+	return nil
+}
+
+// -allow-scope 授权范围,解析出的目标只保留落在其中的主机(取交集,与 -hn 排除不同)
+var AllowNets []*net.IPNet
+
+var OutOfScopeErr = errors.New("targets outside the allowed scope (-allow-scope)")
+
+// 每行一个cidr或ip,支持 # 注释
+func LoadAllowScope() {
+	if AllowScope == "" {
+		return
+	}
+	data, err := os.ReadFile(AllowScope)
+	if err != nil {
+		fmt.Println("[-] allow-scope read error:", err)
+		os.Exit(0)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = stripComment(strings.TrimSpace(line))
+		if line == "" {
+			continue
+		}
+		if !strings.Contains(line, "/") {
+			if ip := net.ParseIP(line); ip != nil && ip.To4() != nil {
+				line += "/32"
+			} else {
+				line += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(line)
+		if err != nil {
+			fmt.Printf("[-] allow-scope invalid line %q: %v\n", line, err)
+			os.Exit(0)
+		}
+		AllowNets = append(AllowNets, ipNet)
+	}
+	if len(AllowNets) == 0 {
+		fmt.Println("[-] allow-scope is empty:", AllowScope)
+		os.Exit(0)
+	}
+	fmt.Printf("[*] allow-scope loaded %d cidrs\n", len(AllowNets))
+}
+
+func InAllowScope(host string) bool {
+	ip := net.ParseIP(strings.Split(host, "%")[0])
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range AllowNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// 去掉授权范围外的主机和 host:port,逐个输出被丢弃的目标;-allow-scope-abort 时有越界目标直接返回错误
+func EnforceAllowScope(hosts []string) ([]string, error) {
+	r := defaultRun(context.Background())
+	r.res.HostPort = HostPort
+	hosts, err := r.enforceAllowScope(hosts)
+	HostPort = r.res.HostPort
+	return hosts, err
+}
+
+func (r *parseRun) enforceAllowScope(hosts []string) ([]string, error) {
+	if len(AllowNets) == 0 {
+		return hosts, nil
+	}
+	var dropped []string
+	keep := func(host string) bool {
+		if InAllowScope(host) {
+			return true
+		}
+		dropped = append(dropped, host)
+		return false
+	}
+	var result []string
+	for _, host := range hosts {
+		if keep(host) {
+			result = append(result, host)
+		}
+	}
+	var hostPorts []string
+	for _, target := range r.res.HostPort {
+		index := strings.LastIndex(target, ":")
+		if keep(strings.Trim(target[:index], "[]")) {
+			hostPorts = append(hostPorts, target)
+		}
+	}
+	r.res.HostPort = hostPorts
+	if len(dropped) == 0 {
+		return result, nil
+	}
+	r.logOutOfScope(dropped, len(dropped))
+	if AllowScopeAbort {
+		return nil, fmt.Errorf("%w: %d targets", OutOfScopeErr, len(dropped))
+	}
+	return result, nil
+}
+
+// 逐个输出被丢弃的目标,最多100个,total 为丢弃总数
+func (r *parseRun) logOutOfScope(dropped []string, total int) {
+	for i, host := range dropped {
+		if i == maxScopeLog {
+			break
+		}
+		r.logf("[!] out of scope, dropped: %s", host)
+	}
+	if total > maxScopeLog {
+		r.logf("[!] ... and %d more", total-maxScopeLog)
+	}
+	r.logf("[!] allow-scope dropped %d targets not in %s", total, AllowScope)
+}
+
+const maxScopeLog = 100
+
+// 把存活主机(可带端口,如 10.0.0.5:80)收敛为恰好覆盖这些地址的最少CIDR,
+// 不连续的地址分成多段,ipv6按/128输出,域名忽略;可直接用于生成防火墙规则
+func SummarizeCIDRs(hosts []string) []string {
+	var ipv4 []string
+	var ipv6 []string
+	seen := make(map[string]struct{})
+	for _, host := range hosts {
+		if strings.Count(host, ":") == 1 || strings.HasPrefix(host, "[") {
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+		} else if index := strings.LastIndex(host, ":"); index != -1 && net.ParseIP(host) == nil {
+			// HostPort 中不带括号的 ipv6:port
+			host = host[:index]
+		}
+		ip := net.ParseIP(strings.Split(host, "%")[0])
+		if ip == nil {
+			continue
+		}
+		if ip.To4() != nil {
+			ipv4 = append(ipv4, ip.String())
+		} else if _, ok := seen[ip.String()]; !ok {
+			seen[ip.String()] = struct{}{}
+			ipv6 = append(ipv6, ip.String()+"/128")
+		}
+	}
+	sort.Strings(ipv6)
+	return append(AggregateCIDRs(ipv4), ipv6...)
+}
+
+// -live-cidrs 扫描后导出存活主机的CIDR汇总
+func WriteLiveCIDRs(filename string, hosts []string) error {
+	cidrs := SummarizeCIDRs(hosts)
+	if err := os.WriteFile(filename, []byte(strings.Join(cidrs, "\n")+"\n"), 0666); err != nil {
+		return err
+	}
+	fmt.Printf("[*] live cidrs saved to %s, cidrs: %d\n", filename, len(cidrs))
+	return nil
+}
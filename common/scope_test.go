@@ -0,0 +1,33 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSummarizeCIDRs(t *testing.T) {
+	full := newTestRun().parseIP1("10.0.0.0-10.0.0.255")
+	withGap := newTestRun().parseIP1("10.0.0.0-10.0.0.255")
+	withGap = append(withGap[:5], withGap[6:]...)
+	tests := []struct {
+		name  string
+		hosts []string
+		want  []string
+	}{
+		{"aligned /24", full, []string{"10.0.0.0/24"}},
+		{"aligned /24 shuffled with ports", []string{"10.0.0.3:80", "10.0.0.1", "10.0.0.0", "10.0.0.2:22"}, []string{"10.0.0.0/30"}},
+		{"unaligned run", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, []string{"10.0.0.1/32", "10.0.0.2/31"}},
+		{"gap in /24", withGap, []string{
+			"10.0.0.0/30", "10.0.0.4/32", "10.0.0.6/31", "10.0.0.8/29", "10.0.0.16/28",
+			"10.0.0.32/27", "10.0.0.64/26", "10.0.0.128/25",
+		}},
+		{"separate blocks", []string{"192.168.1.10", "10.0.0.5", "10.0.0.4"}, []string{"10.0.0.4/31", "192.168.1.10/32"}},
+		{"duplicates", []string{"10.0.0.1", "10.0.0.1:80", "10.0.0.1"}, []string{"10.0.0.1/32"}},
+		{"ipv6 and hostnames", []string{"[2001:db8::1]:443", "2001:db8::1", "web.local", "10.0.0.1"}, []string{"10.0.0.1/32", "2001:db8::1/128"}},
+	}
+	for _, tt := range tests {
+		if got := SummarizeCIDRs(tt.hosts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: SummarizeCIDRs = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	HostTags = make(map[string]string)
+	tagMutex sync.RWMutex
+	// -tag 中的目标写法 -> 标签,解析时给展开出的主机打标签
+	TargetTags = make(map[string]string)
+)
+
+// 解析 -tag prod=10.1.0.0/16;lab=10.99.0.0/16,10.98.0.0/16,打标签的同时加入扫描目标。
+// 这里只记录写法,主机在主解析中展开时打标签,与 -sample、/8 抽样实际选中的主机一致
+func ParseTags(Info *HostInfo) {
+	if Tags == "" {
+		return
+	}
+	for _, group := range strings.Split(Tags, ";") {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+		index := strings.Index(group, "=")
+		if index <= 0 || index == len(group)-1 {
+			fmt.Println("[-] tag parse error:", group)
+			os.Exit(0)
+		}
+		tag, hosts := group[:index], group[index+1:]
+		for _, spec := range splitTargets(hosts) {
+			TargetTags[strings.TrimSpace(spec)] = tag
+		}
+		if Info.Host == "" {
+			Info.Host = hosts
+		} else {
+			Info.Host += "," + hosts
+		}
+	}
+}
+
+func SetHostTag(host, tag string) {
+	tagMutex.Lock()
+	HostTags[host] = tag
+	tagMutex.Unlock()
+}
+
+// 查找结果所属主机的标签
+func ResultTag(result string) string {
+	tagMutex.RLock()
+	defer tagMutex.RUnlock()
+	if len(HostTags) == 0 {
+		return ""
+	}
+	return HostTags[resultHost(result, func(host string) bool {
+		_, ok := HostTags[host]
+		return ok
+	})]
+}
+
+// 结果中的第一个主机:逐个字段取出 ip、ip:port、[ipv6]:port 或 url 中的主机,
+// 不是ip的(域名)只有 known 返回true时才算
+func resultHost(result string, known func(string) bool) string {
+	for _, field := range strings.Fields(result) {
+		field = strings.TrimRight(field, ",;)\"'")
+		var host string
+		switch {
+		case strings.Contains(field, "://"):
+			if u, err := url.Parse(field); err == nil {
+				host = u.Hostname()
+			}
+		case strings.HasPrefix(field, "["):
+			if index := strings.Index(field, "]"); index != -1 {
+				host = field[1:index]
+			}
+		case net.ParseIP(field) != nil:
+			host = field
+		default:
+			host = strings.SplitN(field, ":", 2)[0]
+		}
+		if host == "" {
+			continue
+		}
+		if net.ParseIP(host) != nil || known != nil && known(host) {
+			return host
+		}
+	}
+	return ""
+}
+
+// 拆分文件行尾的 #tag:xxx 标注
+func splitTag(line string) (string, string) {
+	index := strings.Index(line, "#tag:")
+	if index == -1 {
+		return line, ""
+	}
+	return strings.TrimSpace(line[:index]), strings.TrimSpace(line[index+len("#tag:"):])
+}
@@ -0,0 +1,179 @@
+package common
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// -validate 时收集的单条解析问题,Line 为文件行号,来自 -h 时为0
+type ParseError struct {
+	Line   int
+	Input  string
+	Reason string
+}
+
+func (e ParseError) String() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("-h %s: %s", e.Input, e.Reason)
+	}
+	return fmt.Sprintf("line %d %s: %s", e.Line, e.Input, e.Reason)
+}
+
+var hostnameReg = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9_-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9_-]*[a-zA-Z0-9])?)*\.?$`)
+
+// 按 parseIP 的分支规则检查单个目标,返回不能解析的原因
+func validateIP(ip string) error {
+	ip = strings.TrimSpace(ip)
+	if isURLTarget(ip) {
+		u, _, err := parseURLTarget(ip)
+		if err != nil {
+			return err
+		}
+		return validateIP(u.Hostname())
+	}
+	if block, _, ok := splitInlineExclude(ip); ok {
+		if err := validateIP(block); err != nil {
+			return err
+		}
+		for _, entry := range strings.FieldsFunc(ip[strings.Index(ip, "!")+1:], func(r rune) bool { return r == ',' || r == '!' }) {
+			if rule := parseExclude(entry); rule.ipNet == nil && !rule.isRange && net.ParseIP(rule.exact) == nil && !hostnameReg.MatchString(rule.exact) {
+				return fmt.Errorf("invalid exclusion %q", entry)
+			}
+		}
+		return nil
+	}
+	switch {
+	case ip == "":
+		return errors.New("empty target")
+	case isBarePrivate(ip):
+		return fmt.Errorf("ambiguous shorthand, use private:%s or -private-shorthand", ip)
+	case isIntIP(ip):
+		return nil
+	case isLocalTarget(ip):
+		_, err := LocalCIDRs(ip)
+		return err
+	case strings.HasPrefix(ip, "private:"):
+		if _, ok := privateRange(ip); !ok {
+			return errors.New("unknown private range, use private:192, private:172 or private:10")
+		}
+		return nil
+	case strings.Count(ip, ":") >= 2:
+		addr := ip
+		// 去掉 %zone,保留其后的前缀长度
+		if index := strings.Index(addr, "%"); index != -1 {
+			if end := strings.Index(addr[index:], "/"); end != -1 {
+				addr = addr[:index] + addr[index+end:]
+			} else {
+				addr = addr[:index]
+			}
+		}
+		if !strings.Contains(addr, "/") {
+			if net.ParseIP(addr) == nil {
+				return errors.New("invalid ipv6 address")
+			}
+			return nil
+		}
+		_, ipNet, err := net.ParseCIDR(addr)
+		if err != nil {
+			return err
+		}
+		if ones, bits := ipNet.Mask.Size(); bits-ones > 16 {
+			return errors.New("ipv6 range too large, max /112")
+		}
+		return nil
+	case strings.Contains(ip, "/"):
+		_, ipNet, err := net.ParseCIDR(ip)
+		if err != nil {
+			return err
+		}
+		ones, bits := ipNet.Mask.Size()
+		if bits == 32 && (ones != 8 || FullScan) && uint64(1)<<(32-ones) > uint64(MaxIPRange) {
+			return fmt.Errorf("range of %d addresses exceeds MaxIPRange %d", uint64(1)<<(32-ones), MaxIPRange)
+		}
+		return nil
+	case regexp.MustCompile(`[a-zA-Z]+`).MatchString(ip):
+		if !hostnameReg.MatchString(ip) {
+			return errors.New("invalid hostname")
+		}
+		return nil
+	case strings.Contains(ip, "-"):
+		start, end, err := ipRangeBounds(ip)
+		if err != nil {
+			return err
+		}
+		if uint64(end-start)+1 > uint64(MaxIPRange) {
+			return fmt.Errorf("range of %d addresses exceeds MaxIPRange %d", uint64(end-start)+1, MaxIPRange)
+		}
+		return nil
+	case net.ParseIP(ip) == nil:
+		return errors.New("invalid ip")
+	}
+	return nil
+}
+
+// 检查一行目标(逗号列表或 host:ports),行号由调用方给出
+func validateLine(line int, input string) []ParseError {
+	var problems []ParseError
+	hostPart, portPart, hasPort := splitTargetLine(input)
+	if hasPort {
+		if _, err := parseTargetPorts(portPart); err != nil {
+			problems = append(problems, ParseError{Line: line, Input: input, Reason: err.Error()})
+		}
+	}
+	for _, item := range splitTargets(hostPart) {
+		if err := validateIP(item); err != nil {
+			problems = append(problems, ParseError{Line: line, Input: strings.TrimSpace(item), Reason: err.Error()})
+		}
+	}
+	return problems
+}
+
+// 只检查 -h 和 -hf 中的目标,不展开也不扫描,返回全部有问题的条目
+func ValidateTargets(host, filename string) ([]ParseError, error) {
+	var problems []ParseError
+	if host != "" {
+		problems = append(problems, validateLine(0, host)...)
+	}
+	if filename == "" {
+		return problems, nil
+	}
+	file, err := OpenTargetFile(filename)
+	if err != nil {
+		return problems, fmt.Errorf("open %s error, %w", filename, err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text, _ := splitTag(strings.TrimSpace(scanner.Text()))
+		if text = stripComment(text); text != "" {
+			problems = append(problems, validateLine(line, text)...)
+		}
+	}
+	return problems, scanner.Err()
+}
+
+// -validate 输出全部问题后退出,有问题时退出码为1
+func Validate(Info *HostInfo) {
+	if !ValidateOnly {
+		return
+	}
+	problems, err := ValidateTargets(Info.Host, HostFile)
+	if err != nil {
+		fmt.Println("[-] validate error:", err)
+		os.Exit(1)
+	}
+	for _, problem := range problems {
+		fmt.Println("[-] " + problem.String())
+	}
+	if len(problems) > 0 {
+		fmt.Printf("[-] %d invalid targets\n", len(problems))
+		os.Exit(1)
+	}
+	fmt.Println("[+] all targets are valid")
+	os.Exit(0)
+}
@@ -0,0 +1,125 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 允许扫描的时间窗口,如 "Mon-Fri 22:00-04:00",跨零点的窗口归属于开始那天
+type ScanWindow struct {
+	Days  [7]bool
+	Start int
+	End   int
+}
+
+var (
+	scanWindow  *ScanWindow
+	windowMutex sync.Mutex
+	weekdays    = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+func ParseWindow(spec string) (*ScanWindow, error) {
+	fields := strings.Fields(spec)
+	w := &ScanWindow{}
+	var timeSpec string
+	switch len(fields) {
+	case 1:
+		for i := range w.Days {
+			w.Days[i] = true
+		}
+		timeSpec = fields[0]
+	case 2:
+		for _, item := range strings.Split(strings.ToLower(fields[0]), ",") {
+			days := strings.Split(item, "-")
+			start, end := weekdayIndex(days[0]), weekdayIndex(days[len(days)-1])
+			if len(days) > 2 || start < 0 || end < 0 {
+				return nil, errors.New("invalid day: " + item)
+			}
+			for i := start; ; i = (i + 1) % 7 {
+				w.Days[i] = true
+				if i == end {
+					break
+				}
+			}
+		}
+		timeSpec = fields[1]
+	default:
+		return nil, errors.New("invalid window: " + spec)
+	}
+	times := strings.Split(timeSpec, "-")
+	if len(times) != 2 {
+		return nil, errors.New("invalid time range: " + timeSpec)
+	}
+	var err error
+	if w.Start, err = parseClock(times[0]); err != nil {
+		return nil, err
+	}
+	if w.End, err = parseClock(times[1]); err != nil {
+		return nil, err
+	}
+	// 开始和结束相同的窗口永远不在窗口内,会一直暂停;全天请写 00:00-24:00
+	if w.Start == w.End {
+		return nil, errors.New("empty time range, use 00:00-24:00 for the whole day: " + timeSpec)
+	}
+	if w.Start == 24*60 {
+		return nil, errors.New("window can not start at 24:00: " + timeSpec)
+	}
+	return w, nil
+}
+
+func weekdayIndex(day string) int {
+	for i, name := range weekdays {
+		if strings.HasPrefix(day, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// 小时为0-23,只允许 24:00 表示当天结束
+func parseClock(s string) (int, error) {
+	hm := strings.Split(s, ":")
+	if len(hm) != 2 {
+		return 0, errors.New("invalid time: " + s)
+	}
+	h, err1 := strconv.Atoi(hm[0])
+	m, err2 := strconv.Atoi(hm[1])
+	if err1 != nil || err2 != nil || h < 0 || h > 24 || h == 24 && m != 0 || m < 0 || m > 59 {
+		return 0, errors.New("invalid time: " + s)
+	}
+	return h*60 + m, nil
+}
+
+func (w *ScanWindow) Contains(t time.Time) bool {
+	now := t.Hour()*60 + t.Minute()
+	day := int(t.Weekday())
+	if w.Start <= w.End {
+		return w.Days[day] && now >= w.Start && now < w.End
+	}
+	// 跨零点: 22:00-04:00
+	if now >= w.Start {
+		return w.Days[day]
+	}
+	return now < w.End && w.Days[(day+6)%7]
+}
+
+// 不在扫描窗口内时阻塞派发,已经在执行的任务不受影响
+func WaitWindow() {
+	if scanWindow == nil {
+		return
+	}
+	windowMutex.Lock()
+	defer windowMutex.Unlock()
+	if scanWindow.Contains(time.Now()) {
+		return
+	}
+	fmt.Printf("[*] 不在扫描时间窗口 %s 内,暂停派发 已完成 %v/%v\n", Window, End, Num)
+	for !scanWindow.Contains(time.Now()) {
+		time.Sleep(30 * time.Second)
+	}
+	fmt.Printf("[*] 进入扫描时间窗口 %s,继续扫描\n", Window)
+}
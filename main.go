@@ -11,6 +11,14 @@ func main() {
 	start := time.Now()
 	var Info common.HostInfo
 	common.Flag(&Info)
+	if common.ScopeFile != "" {
+		common.RunScopes(common.ScopeFile, &Info, func() {
+			common.Parse(&Info)
+			Plugins.Scan(Info)
+		})
+		fmt.Printf("[*] 扫描结束,耗时: %s\n", time.Since(start))
+		return
+	}
 	common.Parse(&Info)
 	Plugins.Scan(Info)
 	fmt.Printf("[*] 扫描结束,耗时: %s\n", time.Since(start))